@@ -0,0 +1,146 @@
+package accesslog
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tony2001/gobrowscap"
+)
+
+const TEST_INI = `
+[GJK_Browscap_Version]
+Version=1
+
+[DefaultProperties]
+Browser="DefaultProperties"
+Platform="unknown"
+Device_Type="unknown"
+Crawler="false"
+
+[TestBrowser/1.*]
+Parent="DefaultProperties"
+Browser="Test Browser"
+Platform="Linux"
+Device_Type="Desktop"
+
+[TestBot/*]
+Parent="DefaultProperties"
+Browser="Test Bot"
+Crawler="true"
+`
+
+const (
+	TEST_COMBINED_LINE = `127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] "GET /a.gif HTTP/1.0" 200 2326 "http://example.com/" "TestBrowser/1.5 (\"quoted\")"`
+	TEST_COMMON_LINE   = `127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] "GET /a.gif HTTP/1.0" 200 2326`
+	TEST_JSON_LINE     = `{"time":"2000-10-10T13:55:36-07:00","status":200,"http_user_agent":"TestBot/2.0"}`
+)
+
+func loadTestIni(t *testing.T) *gobrowscap.IniFile {
+	iniFile, err := gobrowscap.LoadIniReader(strings.NewReader(TEST_INI), 10)
+	require.NoError(t, err)
+	return iniFile
+}
+
+func TestParseCombined(t *testing.T) {
+	record, err := NewParser(FormatCombined).Parse(TEST_COMBINED_LINE)
+	require.NoError(t, err)
+	assert.Equal(t, `TestBrowser/1.5 ("quoted")`, record.UserAgent)
+	assert.Len(t, record.Fields, 9)
+
+	value, ok := record.Field("request")
+	assert.True(t, ok)
+	assert.Equal(t, "GET /a.gif HTTP/1.0", value)
+
+	record, err = NewParser(FormatCombined).Parse(`1.2.3.4 - - [10/Oct/2000:13:55:36 -0700] "GET / HTTP/1.1" 200 1 "-" "TestBrowser/1.5 \x22nginx\x22" "extra"`)
+	require.NoError(t, err)
+	assert.Equal(t, `TestBrowser/1.5 "nginx"`, record.UserAgent)
+
+	_, err = NewParser(FormatCombined).Parse(TEST_COMMON_LINE)
+	assert.Error(t, err)
+}
+
+func TestParseCommon(t *testing.T) {
+	record, err := NewParser(FormatCommon).Parse(TEST_COMMON_LINE)
+	require.NoError(t, err)
+	assert.Empty(t, record.UserAgent)
+	assert.Len(t, record.Fields, 7)
+
+	_, err = NewParser(FormatCommon).Parse("garbage")
+	assert.Error(t, err)
+}
+
+func TestParseJSON(t *testing.T) {
+	record, err := NewParser(FormatJSON).Parse(TEST_JSON_LINE)
+	require.NoError(t, err)
+	assert.Equal(t, "TestBot/2.0", record.UserAgent)
+	require.Len(t, record.Fields, 3)
+	assert.Equal(t, "time", record.Fields[0].Name)
+	assert.Equal(t, "200", record.Fields[1].Value)
+
+	record, err = NewParser(FormatJSON, "ua").Parse(`{"ua":"TestBot/2.0"}`)
+	require.NoError(t, err)
+	assert.Equal(t, "TestBot/2.0", record.UserAgent)
+
+	_, err = NewParser(FormatJSON).Parse(`["not an object"]`)
+	assert.Error(t, err)
+}
+
+func TestEnrich(t *testing.T) {
+	iniFile := loadTestIni(t)
+
+	var out bytes.Buffer
+	stats, err := Enrich(context.Background(), iniFile, strings.NewReader(TEST_COMBINED_LINE+"\ngarbage\n"), &out, WithOutput(OutputOriginal))
+	require.NoError(t, err)
+	assert.Equal(t, Stats{Lines: 2, Invalid: 1, Matched: 1}, stats)
+	assert.Equal(t, TEST_COMBINED_LINE+` "Test Browser" "Linux" "Desktop" "false"`+"\ngarbage\n", out.String())
+
+	out.Reset()
+	_, err = Enrich(context.Background(), iniFile, strings.NewReader(TEST_JSON_LINE), &out, WithFormat(FormatJSON), WithOutput(OutputOriginal))
+	require.NoError(t, err)
+	assert.Equal(t, `{"time":"2000-10-10T13:55:36-07:00","status":200,"http_user_agent":"TestBot/2.0","browser":"Test Bot","platform":"unknown","device_type":"unknown","is_crawler":true}`+"\n", out.String())
+
+	out.Reset()
+	_, err = Enrich(context.Background(), iniFile, strings.NewReader(TEST_JSON_LINE), &out, WithFormat(FormatJSON))
+	require.NoError(t, err)
+	assert.Equal(t, `{"time":"2000-10-10T13:55:36-07:00","status":200,"http_user_agent":"TestBot/2.0","browser":"Test Bot","platform":"unknown","device_type":"unknown","is_crawler":true}`+"\n", out.String())
+
+	out.Reset()
+	stats, err = Enrich(context.Background(), iniFile, strings.NewReader(TEST_COMMON_LINE+"\ngarbage\n"), &out, WithFormat(FormatCommon), WithOutput(OutputCSV))
+	require.NoError(t, err)
+	assert.Equal(t, Stats{Lines: 2, Invalid: 1}, stats)
+	assert.Equal(t, "remote_addr,ident,remote_user,time_local,request,status,body_bytes_sent,browser,platform,device_type,is_crawler\n"+
+		"127.0.0.1,-,frank,10/Oct/2000:13:55:36 -0700,GET /a.gif HTTP/1.0,200,2326,,,,false\n", out.String())
+
+	/* the user agent is not found */
+	out.Reset()
+	stats, err = Enrich(context.Background(), iniFile, strings.NewReader(`{"http_user_agent":"Unknown/1.0"}`), &out, WithFormat(FormatJSON))
+	require.NoError(t, err)
+	assert.Equal(t, Stats{Lines: 1}, stats)
+	assert.Equal(t, `{"http_user_agent":"Unknown/1.0","browser":"","platform":"","device_type":"","is_crawler":false}`+"\n", out.String())
+
+	/* the existing enrichment fields are replaced */
+	line := `{"browser":"old","http_user_agent":"TestBot/2.0", "is_crawler": false}`
+	for _, output := range []OutputFormat{OutputOriginal, OutputJSONLines} {
+		out.Reset()
+		_, err = Enrich(context.Background(), iniFile, strings.NewReader(line), &out, WithFormat(FormatJSON), WithOutput(output))
+		require.NoError(t, err)
+		assert.Equal(t, `{"browser":"Test Bot","http_user_agent":"TestBot/2.0","is_crawler":true,"platform":"unknown","device_type":"unknown"}`+"\n", out.String(), output)
+	}
+
+	/* a line over the limit is invalid, the following lines are still enriched */
+	out.Reset()
+	longLine := strings.Repeat("x", maxLineLength+1)
+	stats, err = Enrich(context.Background(), iniFile, strings.NewReader(longLine+"\r\n"+TEST_COMBINED_LINE+"\n"+longLine), &out, WithOutput(OutputOriginal))
+	require.NoError(t, err)
+	assert.Equal(t, Stats{Lines: 3, Invalid: 2, Matched: 1}, stats)
+	assert.Equal(t, TEST_COMBINED_LINE+` "Test Browser" "Linux" "Desktop" "false"`+"\n", out.String())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = Enrich(ctx, iniFile, strings.NewReader(TEST_COMBINED_LINE), &out)
+	assert.Equal(t, context.Canceled, err)
+}
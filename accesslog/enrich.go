@@ -0,0 +1,160 @@
+package accesslog
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"strings"
+
+	"github.com/tony2001/gobrowscap"
+)
+
+/* the number of distinct user agents remembered by default, logs tend to repeat them a lot */
+const defaultCacheSize = 10000
+
+// Option configures Enrich.
+type Option func(*options)
+
+type options struct {
+	format          Format
+	output          OutputFormat
+	cache           *gobrowscap.Cache
+	userAgentFields []string
+}
+
+// WithFormat sets the format of the log lines, FormatCombined by default.
+func WithFormat(format Format) Option {
+	return func(o *options) {
+		o.format = format
+	}
+}
+
+// WithOutput sets the format of the enriched records, OutputJSONLines by default.
+func WithOutput(output OutputFormat) Option {
+	return func(o *options) {
+		o.output = output
+	}
+}
+
+// WithCache sets the cache of the search results, e.g. to share it between several logs.
+// A cache of 10000 user agents is used by default.
+func WithCache(cache *gobrowscap.Cache) Option {
+	return func(o *options) {
+		o.cache = cache
+	}
+}
+
+// WithUserAgentFields sets the keys looked up for the User-Agent in JSON log lines.
+func WithUserAgentFields(fields ...string) Option {
+	return func(o *options) {
+		o.userAgentFields = fields
+	}
+}
+
+// Stats counts the lines processed by Enrich.
+type Stats struct {
+	Lines int
+	// Invalid lines could not be parsed. They are copied as is with OutputOriginal and skipped otherwise,
+	// the lines longer than 1MB are always skipped.
+	Invalid int
+	// Matched records have a User-Agent found in the browscap data.
+	Matched int
+}
+
+// Enrich reads the log lines from reader, looks up their User-Agent in iniFile and writes
+// the records with the EnrichmentFields added to writer. It stops with ctx.Err() once ctx is done.
+func Enrich(ctx context.Context, iniFile *gobrowscap.IniFile, reader io.Reader, writer io.Writer, opts ...Option) (Stats, error) {
+	o := new(options)
+	o.format = FormatCombined
+	o.output = OutputJSONLines
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.cache == nil {
+		o.cache = gobrowscap.NewCache(defaultCacheSize, 0)
+	}
+
+	parser := NewParser(o.format, o.userAgentFields...)
+	recordWriter := newRecordWriter(o.output, o.format, writer)
+
+	var stats Stats
+	lines := bufio.NewReaderSize(reader, 64*1024)
+	var buf []byte
+	for {
+		if err := ctx.Err(); err != nil {
+			return stats, err
+		}
+
+		var tooLong bool
+		var readErr error
+		buf, tooLong, readErr = readLine(lines, buf)
+		if readErr != nil && readErr != io.EOF {
+			return stats, readErr
+		}
+		if tooLong {
+			stats.Lines++
+			stats.Invalid++
+			continue
+		}
+
+		line := strings.TrimSuffix(strings.TrimSuffix(string(buf), "\n"), "\r")
+		if line == "" {
+			if readErr == io.EOF {
+				break
+			}
+			continue
+		}
+		stats.Lines++
+
+		record, err := parser.Parse(line)
+		if err != nil {
+			stats.Invalid++
+			if err := recordWriter.writeInvalid(line); err != nil {
+				return stats, err
+			}
+			continue
+		}
+
+		var browser *gobrowscap.Browser
+		if record.UserAgent != "" && record.UserAgent != "-" {
+			browser, err = o.cache.SearchContext(ctx, iniFile, record.UserAgent)
+			if err != nil && err != gobrowscap.ErrNotFound {
+				return stats, err
+			}
+			if browser != nil {
+				stats.Matched++
+			}
+		}
+
+		if err := recordWriter.write(record, enrichment(browser)); err != nil {
+			return stats, err
+		}
+		if readErr == io.EOF {
+			break
+		}
+	}
+	return stats, recordWriter.flush()
+}
+
+/* user agents and JSON lines can be much longer than the 64k buffer */
+const maxLineLength = 1024 * 1024
+
+/* reads the next line into buf, the lines longer than maxLineLength are skipped and reported as too long */
+func readLine(reader *bufio.Reader, buf []byte) ([]byte, bool, error) {
+	buf = buf[:0]
+	tooLong := false
+	for {
+		chunk, err := reader.ReadSlice('\n')
+		if !tooLong {
+			buf = append(buf, chunk...)
+			if len(bytes.TrimSuffix(buf, []byte("\n"))) > maxLineLength {
+				tooLong = true
+				buf = buf[:0]
+			}
+		}
+		if err != bufio.ErrBufferFull {
+			return buf, tooLong, err
+		}
+	}
+}
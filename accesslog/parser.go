@@ -0,0 +1,227 @@
+// Package accesslog annotates web server access logs with the browsers detected by gobrowscap.
+package accesslog
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Format is the format of the access log lines.
+type Format int
+
+const (
+	// FormatCombined is the NCSA combined log format used by default by nginx and Apache.
+	FormatCombined Format = iota
+	// FormatCommon is the NCSA common log format. It has no User-Agent, so the records
+	// are never matched, but they can still be converted.
+	FormatCommon
+	// FormatJSON is a JSON object per line, e.g. nginx with escape=json.
+	FormatJSON
+)
+
+func (format Format) String() string {
+	switch format {
+	case FormatCombined:
+		return "combined"
+	case FormatCommon:
+		return "common"
+	case FormatJSON:
+		return "json"
+	}
+	return fmt.Sprintf("Format(%d)", int(format))
+}
+
+// ParseFormat returns the Format named name: combined, common or json.
+func ParseFormat(name string) (Format, error) {
+	for _, format := range []Format{FormatCombined, FormatCommon, FormatJSON} {
+		if format.String() == name {
+			return format, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown log format '%s'", name)
+}
+
+// DefaultUserAgentFields are the keys looked up in JSON log lines, in this order.
+var DefaultUserAgentFields = []string{"http_user_agent", "user_agent", "userAgent", "agent"}
+
+/* nginx escapes quotes as \x22, Apache as \", anything after the known fields is ignored */
+var ncsaRegexp = regexp.MustCompile(`^(\S+) (\S+) (\S+) \[([^\]]*)\] "((?:[^"\\]|\\.)*)" (\S+) (\S+)(?: "((?:[^"\\]|\\.)*)" "((?:[^"\\]|\\.)*)")?`)
+
+var (
+	commonFieldNames   = []string{"remote_addr", "ident", "remote_user", "time_local", "request", "status", "body_bytes_sent"}
+	combinedFieldNames = append(commonFieldNames[:len(commonFieldNames):len(commonFieldNames)], "http_referer", "http_user_agent")
+)
+
+// Field is a named value of a log line.
+type Field struct {
+	Name  string
+	Value string
+
+	raw json.RawMessage /* the original value of JSON lines */
+}
+
+// Record is a parsed log line.
+type Record struct {
+	Line      string
+	Fields    []Field
+	UserAgent string
+}
+
+// Parser parses log lines of a single Format.
+type Parser struct {
+	format          Format
+	userAgentFields []string
+}
+
+// NewParser returns a Parser of format, looking for the User-Agent of JSON lines in userAgentFields,
+// DefaultUserAgentFields when none are given.
+func NewParser(format Format, userAgentFields ...string) *Parser {
+	parser := new(Parser)
+	parser.format = format
+	parser.userAgentFields = userAgentFields
+	if len(parser.userAgentFields) == 0 {
+		parser.userAgentFields = DefaultUserAgentFields
+	}
+	return parser
+}
+
+// Format returns the Format of the parsed lines.
+func (p *Parser) Format() Format {
+	return p.format
+}
+
+// Parse parses a single log line without the line terminator.
+func (p *Parser) Parse(line string) (*Record, error) {
+	if p.format == FormatJSON {
+		return p.parseJSON(line)
+	}
+	return p.parseNCSA(line)
+}
+
+func (p *Parser) parseNCSA(line string) (*Record, error) {
+	groups := ncsaRegexp.FindStringSubmatchIndex(line)
+	if groups == nil {
+		return nil, fmt.Errorf("invalid %s log line: '%s'", p.format, line)
+	}
+
+	names := commonFieldNames
+	if p.format == FormatCombined {
+		/* the referer and the user agent groups are optional in the regexp */
+		if groups[2*len(combinedFieldNames)] < 0 {
+			return nil, fmt.Errorf("invalid %s log line: '%s'", p.format, line)
+		}
+		names = combinedFieldNames
+	}
+
+	record := new(Record)
+	record.Line = line
+	record.Fields = make([]Field, len(names))
+	for i, name := range names {
+		value := line[groups[2*i+2]:groups[2*i+3]]
+		if strings.IndexByte(value, '\\') >= 0 {
+			value = unescapeNCSA(value)
+		}
+		record.Fields[i] = Field{Name: name, Value: value}
+	}
+
+	if p.format == FormatCombined {
+		record.UserAgent = record.Fields[len(names)-1].Value
+	}
+	return record, nil
+}
+
+func unescapeNCSA(value string) string {
+	var builder strings.Builder
+	for i := 0; i < len(value); i++ {
+		if value[i] != '\\' || i+1 == len(value) {
+			builder.WriteByte(value[i])
+			continue
+		}
+
+		i++
+		switch value[i] {
+		case 'x':
+			if i+2 < len(value) {
+				if char, err := strconv.ParseUint(value[i+1:i+3], 16, 8); err == nil {
+					builder.WriteByte(byte(char))
+					i += 2
+					continue
+				}
+			}
+			builder.WriteString(`\x`)
+		case 't':
+			builder.WriteByte('\t')
+		case 'n':
+			builder.WriteByte('\n')
+		default:
+			builder.WriteByte(value[i])
+		}
+	}
+	return builder.String()
+}
+
+func (p *Parser) parseJSON(line string) (*Record, error) {
+	decoder := json.NewDecoder(strings.NewReader(line))
+	decoder.UseNumber()
+
+	/* walk the tokens instead of decoding a map to keep the order of the keys */
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, fmt.Errorf("invalid json log line: %w", err)
+	}
+	if delim, ok := token.(json.Delim); !ok || delim != '{' {
+		return nil, fmt.Errorf("invalid json log line: expected an object, got '%s'", line)
+	}
+
+	record := new(Record)
+	record.Line = line
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, fmt.Errorf("invalid json log line: %w", err)
+		}
+
+		var raw json.RawMessage
+		if err := decoder.Decode(&raw); err != nil {
+			return nil, fmt.Errorf("invalid json log line: %w", err)
+		}
+
+		field := Field{Name: token.(string), raw: raw}
+		switch {
+		case len(raw) > 0 && raw[0] == '"':
+			if err := json.Unmarshal(raw, &field.Value); err != nil {
+				return nil, fmt.Errorf("invalid json log line: %w", err)
+			}
+		case bytes.Equal(raw, []byte("null")):
+		default:
+			field.Value = string(raw)
+		}
+		record.Fields = append(record.Fields, field)
+	}
+
+	if _, err := decoder.Token(); err != nil {
+		return nil, fmt.Errorf("invalid json log line: %w", err)
+	}
+
+	for _, name := range p.userAgentFields {
+		if value, ok := record.Field(name); ok {
+			record.UserAgent = value
+			break
+		}
+	}
+	return record, nil
+}
+
+// Field returns the value of the field named name.
+func (r *Record) Field(name string) (string, bool) {
+	for _, field := range r.Fields {
+		if field.Name == name {
+			return field.Value, true
+		}
+	}
+	return "", false
+}
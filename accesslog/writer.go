@@ -0,0 +1,250 @@
+package accesslog
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/tony2001/gobrowscap"
+)
+
+// OutputFormat is the format of the enriched records.
+type OutputFormat int
+
+const (
+	// OutputJSONLines writes a JSON object per record with the parsed and the added fields.
+	OutputJSONLines OutputFormat = iota
+	// OutputCSV writes the parsed and the added fields as CSV with a header. The columns are
+	// taken from the first record, the fields missing from it are dropped from the others.
+	OutputCSV
+	// OutputOriginal writes the original lines with the added fields appended to them,
+	// as quoted strings for NCSA logs and as keys of the object for JSON logs.
+	OutputOriginal
+)
+
+func (output OutputFormat) String() string {
+	switch output {
+	case OutputJSONLines:
+		return "jsonl"
+	case OutputCSV:
+		return "csv"
+	case OutputOriginal:
+		return "original"
+	}
+	return fmt.Sprintf("OutputFormat(%d)", int(output))
+}
+
+// ParseOutputFormat returns the OutputFormat named name: jsonl, csv or original.
+func ParseOutputFormat(name string) (OutputFormat, error) {
+	for _, output := range []OutputFormat{OutputJSONLines, OutputCSV, OutputOriginal} {
+		if output.String() == name {
+			return output, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown output format '%s'", name)
+}
+
+// EnrichmentFields are the names of the fields added to every record.
+var EnrichmentFields = []string{"browser", "platform", "device_type", "is_crawler"}
+
+/* the added fields are empty when the user agent is not found, except is_crawler which is false */
+func enrichment(browser *gobrowscap.Browser) []Field {
+	fields := make([]Field, len(EnrichmentFields))
+	for i, name := range EnrichmentFields {
+		fields[i].Name = name
+	}
+
+	isCrawler := false
+	if browser != nil {
+		fields[0].Value = browser.Browser
+		fields[1].Value = browser.Platform
+		fields[2].Value = browser.DeviceType
+		isCrawler = browser.IsCrawler
+	}
+	fields[3].Value = strconv.FormatBool(isCrawler)
+	fields[3].raw = json.RawMessage(fields[3].Value)
+	return fields
+}
+
+/* the added fields replace the fields of the record with the same names, the others are appended */
+func mergeFields(fields []Field, added []Field) ([]Field, bool) {
+	merged := make([]Field, len(fields), len(fields)+len(added))
+	copy(merged, fields)
+
+	replaced := false
+	for _, field := range added {
+		found := false
+		for i := range merged[:len(fields)] {
+			if merged[i].Name == field.Name {
+				merged[i] = field
+				found = true
+			}
+		}
+		if found {
+			replaced = true
+		} else {
+			merged = append(merged, field)
+		}
+	}
+	return merged, replaced
+}
+
+type recordWriter interface {
+	write(record *Record, added []Field) error
+	/* writes a line that could not be parsed, if the output format allows it */
+	writeInvalid(line string) error
+	flush() error
+}
+
+func newRecordWriter(output OutputFormat, format Format, writer io.Writer) recordWriter {
+	switch output {
+	case OutputCSV:
+		return &csvWriter{writer: csv.NewWriter(writer)}
+	case OutputOriginal:
+		return &originalWriter{writer: bufio.NewWriter(writer), format: format}
+	}
+	return &jsonLinesWriter{writer: bufio.NewWriter(writer)}
+}
+
+type jsonLinesWriter struct {
+	writer *bufio.Writer
+}
+
+func (w *jsonLinesWriter) write(record *Record, added []Field) error {
+	fields, _ := mergeFields(record.Fields, added)
+	return writeJSONObject(w.writer, fields)
+}
+
+func writeJSONObject(writer *bufio.Writer, fields []Field) error {
+	writer.WriteByte('{')
+	for i, field := range fields {
+		if i > 0 {
+			writer.WriteByte(',')
+		}
+		if err := writeJSONField(writer, field); err != nil {
+			return err
+		}
+	}
+	writer.WriteString("}\n")
+	return nil
+}
+
+func (w *jsonLinesWriter) writeInvalid(line string) error {
+	return nil
+}
+
+func (w *jsonLinesWriter) flush() error {
+	return w.writer.Flush()
+}
+
+func writeJSONField(writer *bufio.Writer, field Field) error {
+	name, err := json.Marshal(field.Name)
+	if err != nil {
+		return err
+	}
+	writer.Write(name)
+	writer.WriteByte(':')
+
+	if field.raw != nil {
+		writer.Write(field.raw)
+		return nil
+	}
+
+	value, err := json.Marshal(field.Value)
+	if err != nil {
+		return err
+	}
+	writer.Write(value)
+	return nil
+}
+
+type csvWriter struct {
+	writer *csv.Writer
+	header []string
+}
+
+func (w *csvWriter) write(record *Record, added []Field) error {
+	fields, _ := mergeFields(record.Fields, added)
+
+	if w.header == nil {
+		w.header = make([]string, len(fields))
+		for i, field := range fields {
+			w.header[i] = field.Name
+		}
+		if err := w.writer.Write(w.header); err != nil {
+			return err
+		}
+	}
+
+	values := make(map[string]string, len(fields))
+	for _, field := range fields {
+		values[field.Name] = field.Value
+	}
+
+	row := make([]string, len(w.header))
+	for i, name := range w.header {
+		row[i] = values[name]
+	}
+	return w.writer.Write(row)
+}
+
+func (w *csvWriter) writeInvalid(line string) error {
+	return nil
+}
+
+func (w *csvWriter) flush() error {
+	w.writer.Flush()
+	return w.writer.Error()
+}
+
+var ncsaEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+type originalWriter struct {
+	writer *bufio.Writer
+	format Format
+}
+
+func (w *originalWriter) write(record *Record, added []Field) error {
+	if w.format != FormatJSON {
+		w.writer.WriteString(record.Line)
+		for _, field := range added {
+			w.writer.WriteString(` "`)
+			w.writer.WriteString(ncsaEscaper.Replace(field.Value))
+			w.writer.WriteByte('"')
+		}
+		w.writer.WriteByte('\n')
+		return nil
+	}
+
+	/* the line is written again only if it already has some of the added fields */
+	if fields, replaced := mergeFields(record.Fields, added); replaced {
+		return writeJSONObject(w.writer, fields)
+	}
+
+	/* insert the added fields before the closing brace of the object */
+	line := strings.TrimRight(record.Line, " \t\r")
+	w.writer.WriteString(strings.TrimSuffix(line, "}"))
+	for i, field := range added {
+		if i > 0 || len(record.Fields) > 0 {
+			w.writer.WriteByte(',')
+		}
+		if err := writeJSONField(w.writer, field); err != nil {
+			return err
+		}
+	}
+	w.writer.WriteString("}\n")
+	return nil
+}
+
+func (w *originalWriter) writeInvalid(line string) error {
+	w.writer.WriteString(line)
+	return w.writer.WriteByte('\n')
+}
+
+func (w *originalWriter) flush() error {
+	return w.writer.Flush()
+}
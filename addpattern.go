@@ -0,0 +1,195 @@
+package gobrowscap
+
+import (
+	"fmt"
+	"sort"
+)
+
+type addedPattern struct {
+	pattern    string
+	properties map[string]string
+	parent     string
+}
+
+// AddPattern adds a browscap section named pattern, a wildcard pattern like "MyApp/* (Linux*)",
+// with properties and inheriting the rest of them from the section named parent, if not empty.
+// The detector switches to a copy of the current IniFile with the pattern inserted at its place
+// in the search order, only the batch containing it is recompiled. The added patterns are added
+// again after every reload, the ones that can't be added anymore are dropped and reported
+// to the OnReloadError callback. Replace drops all of them.
+func (d *Detector) AddPattern(pattern string, properties map[string]string, parent string) error {
+	iniFile, err := d.addPattern(pattern, properties, parent)
+	if err != nil {
+		return err
+	}
+	d.reloaded(iniFile)
+	return nil
+}
+
+func (d *Detector) addPattern(pattern string, properties map[string]string, parent string) (*IniFile, error) {
+	d.reloadMu.Lock()
+	defer d.reloadMu.Unlock()
+
+	added := addedPattern{pattern: pattern, properties: make(map[string]string, len(properties)), parent: parent}
+	for key, value := range properties {
+		added.properties[key] = value
+	}
+
+	iniFile, err := addPattern(d.IniFile(), added.pattern, added.properties, added.parent)
+	if err != nil {
+		return nil, err
+	}
+	d.added = append(d.added, added)
+	d.replace(iniFile)
+	return iniFile, nil
+}
+
+/* adds the patterns added to the previous file to the reloaded one */
+func (d *Detector) addPatternsAgain(iniFile *IniFile) (*IniFile, []error) {
+	var errs []error
+	kept := d.added[:0]
+	for _, added := range d.added {
+		withPattern, err := addPattern(iniFile, added.pattern, added.properties, added.parent)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("dropped the added pattern '%s': %w", added.pattern, err))
+			continue
+		}
+		iniFile = withPattern
+		kept = append(kept, added)
+	}
+	d.added = kept
+	return iniFile, errs
+}
+
+/* copy-on-write, the searches running on iniFile are not affected */
+func addPattern(iniFile *IniFile, pattern string, properties map[string]string, parent string) (*IniFile, error) {
+	if pattern == "" {
+		return nil, fmt.Errorf("empty pattern")
+	}
+
+	parentIndex := -1
+	for index, section := range iniFile.sections {
+		if section.name == pattern {
+			return nil, &ParseError{Section: pattern, Value: pattern, Kind: ParseErrorDuplicateSection}
+		}
+		if parent != "" && section.name == parent {
+			parentIndex = index
+		}
+	}
+	if parent != "" && parentIndex < 0 {
+		return nil, &ParseError{Section: pattern, Key: "Parent", Value: parent, Kind: ParseErrorUnknownParent}
+	}
+
+	sectionIndex := len(iniFile.sections)
+	section := new(IniSection)
+	section.name = pattern
+	if parent != "" {
+		parseSectionValues(section, "Parent", parent, 0)
+		section.parent = parentIndex
+	}
+
+	/* the properties are kept in a stable order, like the ones read from a file */
+	keys := make([]string, 0, len(properties))
+	for key := range properties {
+		if key != "Parent" {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if _, parseErr := parseSectionValues(section, key, properties[key], 0); parseErr != nil {
+			parseErr.Section = pattern
+			return nil, parseErr
+		}
+	}
+
+	matcher := iniFile.options.matcher
+	ready, err := newPattern(matcher, sectionPatternString(pattern), &DeduplicatedPattern{intval: sectionIndex, position: sectionIndex})
+	if err != nil {
+		return nil, err
+	}
+
+	position := sort.Search(len(iniFile.patterns), func(i int) bool {
+		return !patternLess(iniFile.patterns[i], ready)
+	})
+	patterns := make([]*Pattern, 0, len(iniFile.patterns)+1)
+	patterns = append(patterns, iniFile.patterns[:position]...)
+	patterns = append(patterns, ready)
+	patterns = append(patterns, iniFile.patterns[position:]...)
+
+	batches, err := insertIntoBatches(matcher, iniFile.batches, patterns, position, iniFile.batchSize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile batch regex: %w", err)
+	}
+
+	sections := make(map[int]*IniSection, len(iniFile.sections)+1)
+	for index, existing := range iniFile.sections {
+		sections[index] = existing
+	}
+	sections[sectionIndex] = section
+
+	var pool *workerPool
+	if iniFile.pool != nil {
+		pool = iniFile.pool.share()
+	}
+	result := newIniFileWithPool(iniFile.options, pool)
+	result.patterns = patterns
+	result.sections = sections
+	result.batches = batches
+	result.index = iniFile.index.insert(position, ready)
+	if iniFile.options.precomputeBrowsers {
+		section.resolved = resolveBrowser(result, ready, sectionIndex)
+	}
+	result.batchSize = iniFile.batchSize
+	result.version = iniFile.version
+	result.released = iniFile.released
+	result.fileType = iniFile.fileType
+	result.checksum = iniFile.checksum
+	result.source = iniFile.source
+	result.parse = iniFile.parse
+	result.loadDuration = iniFile.loadDuration
+
+	return result, nil
+}
+
+/*
+recompiles the batch the pattern at position is inserted into, the following ones are only shifted.
+The batch is split in two once it's larger than batchSize.
+*/
+func insertIntoBatches(matcher Matcher, batches []*Batch, patterns []*Pattern, position int, batchSize int) ([]*Batch, error) {
+	if len(batches) == 0 {
+		return compileAndAddBatchRegex(matcher, nil, batchRegexString(patterns), 0, 0, len(patterns))
+	}
+
+	/* a pattern appended after the last one goes to the last batch */
+	target := sort.Search(len(batches), func(i int) bool { return batches[i].end > position })
+	if target == len(batches) {
+		target--
+	}
+
+	result := make([]*Batch, 0, len(batches))
+	result = append(result, batches[:target]...)
+
+	start, end := batches[target].start, batches[target].end+1
+	ranges := [][2]int{{start, end}}
+	if end-start > batchSize {
+		middle := start + (end-start+1)/2
+		ranges = [][2]int{{start, middle}, {middle, end}}
+	}
+	for _, batchRange := range ranges {
+		var err error
+		result, err = compileAndAddBatchRegex(matcher, result, batchRegexString(patterns[batchRange[0]:batchRange[1]]), len(result), batchRange[0], batchRange[1])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, batch := range batches[target+1:] {
+		shifted := *batch
+		shifted.index = len(result)
+		shifted.start++
+		shifted.end++
+		result = append(result, &shifted)
+	}
+	return result, nil
+}
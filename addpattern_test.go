@@ -0,0 +1,127 @@
+package gobrowscap
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAddPattern(t *testing.T) {
+	var versions []string
+	var reloadErrors []error
+	detector := NewDetector(FILE,
+		OnReload(func(version string) { versions = append(versions, version) }),
+		OnReloadError(func(err error) { reloadErrors = append(reloadErrors, err) }))
+	require.NoError(t, detector.AddPattern("MyApp/* (Linux*)", map[string]string{"Browser": "My App", "Version": "2.0"}, "DefaultProperties"))
+	require.NoError(t, detector.AddPattern("Mozilla/5.0 (compatible; MyCrawler/1.0; +http://example.com/crawler)", map[string]string{"Crawler": "true"}, "Googlebot"))
+
+	browser, err := detector.Search("MyApp/2.1 (Linux x86_64)")
+	require.NoError(t, err)
+	assert.Equal(t, "My App", browser.Browser)
+	assert.Equal(t, "2.0", browser.Version)
+
+	browser, err = detector.Search("Mozilla/5.0 (compatible; MyCrawler/1.0; +http://example.com/crawler)")
+	require.NoError(t, err)
+	assert.Equal(t, "Googlebot", browser.Browser)
+	assert.True(t, browser.IsCrawler)
+
+	/* the searches on the previous IniFile are not affected */
+	browser, err = SearchBrowser(FILE, "MyApp/2.1 (Linux x86_64)")
+	require.NoError(t, err)
+	assert.Equal(t, "Default Browser", browser.Browser)
+
+	for _, userAgent := range []string{
+		"Mozilla/5.0 (iPhone; CPU iPhone OS 7_0 like Mac OS X) AppleWebKit/537.51.1 (KHTML, like Gecko) Version/7.0 Mobile/11A465 Safari/9537.53",
+		"Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)",
+	} {
+		expected, err := SearchBrowser(FILE, userAgent)
+		require.NoError(t, err)
+		browser, err := detector.Search(userAgent)
+		require.NoError(t, err)
+		assert.Equal(t, expected, browser)
+	}
+
+	/* the patterns are in the same order as if the sections were in the file */
+	data, err := ioutil.ReadFile(TEST_INI_FILE)
+	require.NoError(t, err)
+	data = append(data, []byte(`
+[MyApp/* (Linux*)]
+Parent="DefaultProperties"
+Browser="My App"
+Version="2.0"
+
+[Mozilla/5.0 (compatible; MyCrawler/1.0; +http://example.com/crawler)]
+Parent="Googlebot"
+Crawler="true"
+`)...)
+	expected, err := LoadIniReader(bytes.NewReader(data), 10)
+	require.NoError(t, err)
+
+	iniFile := detector.IniFile()
+	require.Len(t, iniFile.patterns, len(expected.patterns))
+	for i, pattern := range iniFile.patterns {
+		assert.Equal(t, expected.patterns[i].patternStr, pattern.patternStr)
+	}
+	assert.Equal(t, FILE.checksum, iniFile.checksum)
+	assert.Equal(t, []string{GetFileVersion(FILE), GetFileVersion(FILE)}, versions)
+
+	checkBatches := func(iniFile *IniFile) {
+		end := 0
+		for i, batch := range iniFile.batches {
+			assert.Equal(t, i, batch.index)
+			assert.Equal(t, end, batch.start)
+			assert.LessOrEqual(t, batch.end-batch.start, iniFile.batchSize)
+			assert.Equal(t, batchRegexString(iniFile.patterns[batch.start:batch.end]), batch.patternStr)
+			end = batch.end
+		}
+		assert.Equal(t, len(iniFile.patterns), end)
+
+		/* the index built incrementally finds the same candidates as the one built at once */
+		index := newTokenIndex(iniFile.patterns)
+		assert.Equal(t, index.words, iniFile.index.words)
+		for i, pattern := range iniFile.patterns {
+			userAgent := strings.NewReplacer(`\`, "", ".*", " ", ".", " ", `(\d)`, "0").Replace(pattern.patternStr)
+			assert.Contains(t, iniFile.index.candidates(userAgent), i, userAgent)
+			assert.Equal(t, index.candidates(userAgent), iniFile.index.candidates(userAgent), userAgent)
+		}
+	}
+	checkBatches(iniFile)
+	assert.Same(t, FILE.pool, iniFile.pool)
+
+	var parseErr *ParseError
+	require.True(t, errors.As(detector.AddPattern("MyApp/* (Linux*)", nil, ""), &parseErr))
+	assert.Equal(t, ParseErrorDuplicateSection, parseErr.Kind)
+	require.True(t, errors.As(detector.AddPattern("OtherApp/*", nil, "Missing"), &parseErr))
+	assert.Equal(t, ParseErrorUnknownParent, parseErr.Kind)
+	require.True(t, errors.As(detector.AddPattern("OtherApp/*", map[string]string{"isTablet": "maybe"}, ""), &parseErr))
+	assert.Equal(t, ParseErrorInvalidBool, parseErr.Kind)
+	assert.Len(t, detector.IniFile().patterns, len(expected.patterns))
+
+	/* the batches stay within the batch size when the patterns are added to the same batch */
+	for i := 0; i < 25; i++ {
+		require.NoError(t, detector.AddPattern(fmt.Sprintf("HotfixApp%02d/*", i), map[string]string{"Browser": "Hotfix"}, ""))
+	}
+	checkBatches(detector.IniFile())
+
+	/* the added patterns survive the reloads, the ones that can't be added anymore are reported */
+	require.NoError(t, detector.ReloadFrom(bytes.NewReader(data)))
+	require.Len(t, reloadErrors, 2)
+	assert.Contains(t, reloadErrors[0].Error(), "MyApp/* (Linux*)")
+	assert.Contains(t, reloadErrors[1].Error(), "Mozilla/5.0 (compatible; MyCrawler/1.0")
+	browser, err = detector.Search("HotfixApp07/1.0")
+	require.NoError(t, err)
+	assert.Equal(t, "Hotfix", browser.Browser)
+	checkBatches(detector.IniFile())
+
+	detector.Replace(FILE)
+	require.NoError(t, detector.ReloadFrom(bytes.NewReader(data[:len(data)-1])))
+	browser, err = detector.Search("HotfixApp07/1.0")
+	require.NoError(t, err)
+	assert.Equal(t, "Default Browser", browser.Browser)
+}
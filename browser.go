@@ -23,4 +23,29 @@ type Browser struct {
 	DeviceName           string
 	DeviceCodeName       string
 	DeviceBrandName      string
+	// Properties holds every property of the matched section and its parents
+	// keyed by the browscap name, e.g. "RenderingEngine_Name" or "Platform_Bits".
+	Properties map[string]string
+	// Overlay is the path of the overlay file the matched section comes from, see WithOverlays.
+	Overlay string
+	// HintedFields lists the fields set from the Client Hints, see ApplyClientHints.
+	HintedFields []string
+}
+
+func copyBrowser(browser *Browser) *Browser {
+	if browser == nil {
+		return nil
+	}
+
+	browserCopy := *browser
+	if browser.Properties != nil {
+		browserCopy.Properties = make(map[string]string, len(browser.Properties))
+		for key, value := range browser.Properties {
+			browserCopy.Properties[key] = value
+		}
+	}
+	if browser.HintedFields != nil {
+		browserCopy.HintedFields = append([]string(nil), browser.HintedFields...)
+	}
+	return &browserCopy
 }
@@ -0,0 +1,148 @@
+package gobrowscap
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"sync"
+)
+
+/* the number of the most recent distinct results reused by SearchBrowsersStream */
+const streamCacheSize = 10000
+
+// BrowserResult is the result of searching a single user agent with SearchBrowsers.
+type BrowserResult struct {
+	UserAgent string
+	Browser   *Browser
+	Err       error
+}
+
+// SearchBrowsers searches all the user agents and returns the results in the same order.
+// The user agents are searched in parallel and every distinct one is searched only once.
+func SearchBrowsers(iniFile *IniFile, userAgents []string) []BrowserResult {
+	return SearchBrowsersContext(context.Background(), iniFile, userAgents)
+}
+
+// SearchBrowsersContext is SearchBrowsers respecting the cancellation of ctx,
+// the user agents not searched before ctx is done get ctx.Err() as their error.
+func SearchBrowsersContext(ctx context.Context, iniFile *IniFile, userAgents []string) []BrowserResult {
+	results := make([]BrowserResult, len(userAgents))
+
+	/* index of the first occurrence of every distinct user agent */
+	firstIndexes := make(map[string]int, len(userAgents))
+	uniqueIndexes := make([]int, 0, len(userAgents))
+	for i, userAgent := range userAgents {
+		if _, ok := firstIndexes[userAgent]; !ok {
+			firstIndexes[userAgent] = i
+			uniqueIndexes = append(uniqueIndexes, i)
+		}
+	}
+
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < runtime.NumCPU(); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range indexes {
+				browser, err := search(ctx, iniFile, userAgents[index], true)
+				results[index] = BrowserResult{UserAgent: userAgents[index], Browser: browser, Err: err}
+			}
+		}()
+	}
+
+	for _, index := range uniqueIndexes {
+		indexes <- index
+	}
+	close(indexes)
+	wg.Wait()
+
+	for i, userAgent := range userAgents {
+		if first := firstIndexes[userAgent]; first != i {
+			results[i] = BrowserResult{UserAgent: userAgent, Browser: copyBrowser(results[first].Browser), Err: results[first].Err}
+		}
+	}
+	return results
+}
+
+// SearchBrowsersStream searches the user agents received from userAgents in parallel and sends
+// the results to the returned channel in the same order. The results of the recently searched
+// user agents are reused. The returned channel is closed once userAgents is closed and all
+// the results are sent, or when ctx is done.
+func SearchBrowsersStream(ctx context.Context, iniFile *IniFile, userAgents <-chan string) <-chan BrowserResult {
+	workers := runtime.NumCPU()
+	cache := NewCache(streamCacheSize, 0)
+
+	type streamJob struct {
+		userAgent string
+		result    chan BrowserResult
+	}
+
+	jobs := make(chan streamJob)
+	/* the result channels in the input order, bounding the number of the pending results as well */
+	pending := make(chan chan BrowserResult, workers*4)
+	results := make(chan BrowserResult)
+
+	for i := 0; i < workers; i++ {
+		go func() {
+			for job := range jobs {
+				result := BrowserResult{UserAgent: job.userAgent}
+				if entry := cache.get(iniFile, job.userAgent); entry != nil {
+					result.Browser, result.Err = copyBrowser(entry.browser), entry.err
+				} else {
+					result.Browser, result.Err = search(ctx, iniFile, job.userAgent, true)
+					if result.Err == nil || errors.Is(result.Err, ErrNotFound) {
+						cache.add(iniFile, job.userAgent, result.Browser, result.Err)
+						result.Browser = copyBrowser(result.Browser)
+					}
+				}
+				job.result <- result
+			}
+		}()
+	}
+
+	go func() {
+		defer close(pending)
+		defer close(jobs)
+
+		for {
+			var userAgent string
+			var ok bool
+			select {
+			case userAgent, ok = <-userAgents:
+				if !ok {
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+
+			job := streamJob{userAgent: userAgent, result: make(chan BrowserResult, 1)}
+			select {
+			case jobs <- job:
+			case <-ctx.Done():
+				return
+			}
+
+			select {
+			case pending <- job.result:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	go func() {
+		defer close(results)
+
+		for result := range pending {
+			select {
+			case results <- <-result:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return results
+}
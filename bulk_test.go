@@ -0,0 +1,42 @@
+package gobrowscap
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSearchBrowsers(t *testing.T) {
+	iniFile, err := LoadIniReader(strings.NewReader(TEST_SMALL_INI), 10)
+	require.NoError(t, err)
+
+	userAgents := []string{"TestBrowser/1.5", "Unknown/1.0", "TestBrowser/1.5", "TestBrowser/1.7"}
+	results := SearchBrowsers(iniFile, userAgents)
+	require.Len(t, results, len(userAgents))
+
+	for i, userAgent := range userAgents {
+		expected, expectedErr := SearchBrowser(iniFile, userAgent)
+		assert.Equal(t, userAgent, results[i].UserAgent)
+		assert.Equal(t, expectedErr, results[i].Err)
+		assert.Equal(t, expected, results[i].Browser)
+	}
+	assert.NotSame(t, results[0].Browser, results[2].Browser)
+
+	input := make(chan string)
+	go func() {
+		for _, userAgent := range userAgents {
+			input <- userAgent
+		}
+		close(input)
+	}()
+
+	i := 0
+	for result := range SearchBrowsersStream(context.Background(), iniFile, input) {
+		assert.Equal(t, results[i], result)
+		i++
+	}
+	assert.Equal(t, len(userAgents), i)
+}
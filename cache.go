@@ -0,0 +1,163 @@
+package gobrowscap
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// Cache is a bounded LRU cache of SearchBrowser results keyed by user agent.
+// It is bound to the most recently loaded IniFile it has seen and is purged when a newer one is searched.
+// A Detector binds its cache to the IniFile it uses, so that it keeps working after a rollback to an older one.
+type Cache struct {
+	mu         sync.Mutex
+	size       int
+	ttl        time.Duration
+	generation uint64
+	bound      bool /* the generation is set by bind and is not switched by the searches */
+	entries    map[string]*list.Element
+	lru        *list.List
+	hits       uint64
+	misses     uint64
+}
+
+type cacheEntry struct {
+	userAgent string
+	browser   *Browser
+	err       error
+	expires   time.Time
+}
+
+// CacheStats holds the Cache counters.
+type CacheStats struct {
+	Hits   uint64
+	Misses uint64
+	Len    int
+}
+
+// NewCache creates a cache holding up to size results, each for ttl at most.
+// Zero ttl means the results don't expire.
+func NewCache(size int, ttl time.Duration) *Cache {
+	c := new(Cache)
+	c.size = size
+	c.ttl = ttl
+	c.entries = make(map[string]*list.Element, size)
+	c.lru = list.New()
+	return c
+}
+
+// Search returns a copy of the cached result for userAgent or calls SearchBrowser and caches its result.
+func (c *Cache) Search(iniFile *IniFile, userAgent string) (*Browser, error) {
+	return c.SearchContext(context.Background(), iniFile, userAgent)
+}
+
+// SearchContext is Search calling SearchBrowserContext on cache misses.
+func (c *Cache) SearchContext(ctx context.Context, iniFile *IniFile, userAgent string) (*Browser, error) {
+	if entry := c.get(iniFile, userAgent); entry != nil {
+		return copyBrowser(entry.browser), entry.err
+	}
+
+	browser, err := SearchBrowserContext(ctx, iniFile, userAgent)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return nil, err
+	}
+
+	c.add(iniFile, userAgent, browser, err)
+	return copyBrowser(browser), err
+}
+
+// Stats returns the current cache counters.
+func (c *Cache) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return CacheStats{Hits: c.hits, Misses: c.misses, Len: c.lru.Len()}
+}
+
+// Purge removes all the cached results, the cache is bound to the next searched IniFile.
+func (c *Cache) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.purge()
+	c.generation = 0
+	c.bound = false
+}
+
+/* purges the cache and keeps it for the searches on iniFile only */
+func (c *Cache) bind(iniFile *IniFile) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.purge()
+	c.generation = iniFile.generation
+	c.bound = true
+}
+
+func (c *Cache) purge() {
+	c.entries = make(map[string]*list.Element, c.size)
+	c.lru.Init()
+}
+
+func (c *Cache) get(iniFile *IniFile, userAgent string) *cacheEntry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.bound && iniFile.generation > c.generation {
+		c.purge()
+		c.generation = iniFile.generation
+	}
+
+	/* searches still running on a replaced IniFile bypass the cache */
+	if iniFile.generation != c.generation {
+		c.misses++
+		return nil
+	}
+
+	element, ok := c.entries[userAgent]
+	if !ok {
+		c.misses++
+		return nil
+	}
+
+	entry := element.Value.(*cacheEntry)
+	if c.ttl > 0 && time.Now().After(entry.expires) {
+		c.lru.Remove(element)
+		delete(c.entries, userAgent)
+		c.misses++
+		return nil
+	}
+
+	c.lru.MoveToFront(element)
+	c.hits++
+	return entry
+}
+
+func (c *Cache) add(iniFile *IniFile, userAgent string, browser *Browser, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if iniFile.generation != c.generation || c.size <= 0 {
+		return
+	}
+
+	entry := &cacheEntry{userAgent: userAgent, browser: browser, err: err}
+	if c.ttl > 0 {
+		entry.expires = time.Now().Add(c.ttl)
+	}
+
+	if element, ok := c.entries[userAgent]; ok {
+		element.Value = entry
+		c.lru.MoveToFront(element)
+		return
+	}
+
+	c.entries[userAgent] = c.lru.PushFront(entry)
+	for c.lru.Len() > c.size {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).userAgent)
+	}
+}
@@ -0,0 +1,71 @@
+package gobrowscap
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCache(t *testing.T) {
+	cache := NewCache(2, time.Hour)
+
+	browser, err := cache.Search(FILE, TEST_USER_AGENT)
+	require.NoError(t, err)
+	assert.Equal(t, "Chrome", browser.Browser)
+	browser.Browser = "Modified"
+	browser.Properties["Browser"] = "Modified"
+
+	browser, err = cache.Search(FILE, TEST_USER_AGENT)
+	require.NoError(t, err)
+	assert.Equal(t, "Chrome", browser.Browser)
+	assert.Equal(t, "Chrome", browser.Properties["Browser"])
+	assert.Equal(t, CacheStats{Hits: 1, Misses: 1, Len: 1}, cache.Stats())
+
+	for _, ua := range []string{TEST_IPHONE_AGENT, TEST_YANDEX_AGENT, TEST_USER_AGENT} {
+		_, err = cache.Search(FILE, ua)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, CacheStats{Hits: 1, Misses: 4, Len: 2}, cache.Stats())
+
+	/* a newly loaded file invalidates the cache, the old one bypasses it */
+	iniFile, err := LoadIniReader(strings.NewReader(TEST_SMALL_INI), 10)
+	require.NoError(t, err)
+	_, err = cache.Search(iniFile, TEST_USER_AGENT)
+	assert.True(t, errors.Is(err, ErrNotFound))
+	_, err = cache.Search(iniFile, TEST_USER_AGENT)
+	assert.True(t, errors.Is(err, ErrNotFound))
+	_, err = cache.Search(FILE, TEST_USER_AGENT)
+	require.NoError(t, err)
+	assert.Equal(t, CacheStats{Hits: 2, Misses: 6, Len: 1}, cache.Stats())
+
+	detector := NewDetector(FILE, WithCache(10, 0))
+	_, err = detector.Search(TEST_USER_AGENT)
+	require.NoError(t, err)
+	_, err = detector.Search(TEST_USER_AGENT)
+	require.NoError(t, err)
+	assert.Equal(t, CacheStats{Hits: 1, Misses: 1, Len: 1}, detector.Cache().Stats())
+
+	/* the cache keeps working after a rollback to an older file */
+	detector.Replace(iniFile)
+	_, err = detector.Search(TEST_USER_AGENT)
+	assert.True(t, errors.Is(err, ErrNotFound))
+	detector.Replace(FILE)
+	for i := 0; i < 2; i++ {
+		browser, err = detector.Search(TEST_USER_AGENT)
+		require.NoError(t, err)
+		assert.Equal(t, "Chrome", browser.Browser)
+	}
+	assert.Equal(t, CacheStats{Hits: 2, Misses: 3, Len: 1}, detector.Cache().Stats())
+
+	/* a purged cache is bound to the next searched file, even an older one */
+	cache.Purge()
+	for i := 0; i < 2; i++ {
+		_, err = cache.Search(FILE, TEST_USER_AGENT)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 1, cache.Stats().Len)
+}
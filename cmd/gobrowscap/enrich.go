@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/tony2001/gobrowscap"
+	"github.com/tony2001/gobrowscap/accesslog"
+)
+
+func runEnrich(args []string, std streams) error {
+	flags, load := newFlagSet("enrich", "[log file...]", std)
+	logFormat := flags.String("log-format", "combined", "format of the log lines: combined, common or json")
+	output := flags.String("output", "jsonl", "output format: jsonl, csv or original")
+	cacheSize := flags.Int("cache-size", 10000, "number of distinct user agents to cache")
+	userAgentFields := flags.String("ua-fields", strings.Join(accesslog.DefaultUserAgentFields, ","), "comma-separated keys of the User-Agent in json log lines")
+	quiet := flags.Bool("q", false, "don't print the line counts to stderr")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	format, err := accesslog.ParseFormat(*logFormat)
+	if err != nil {
+		return err
+	}
+	outputFormat, err := accesslog.ParseOutputFormat(*output)
+	if err != nil {
+		return err
+	}
+
+	iniFile, err := load.load()
+	if err != nil {
+		return err
+	}
+	defer iniFile.Close()
+
+	opts := []accesslog.Option{
+		accesslog.WithFormat(format),
+		accesslog.WithOutput(outputFormat),
+		accesslog.WithCache(gobrowscap.NewCache(*cacheSize, 0)),
+		accesslog.WithUserAgentFields(strings.Split(*userAgentFields, ",")...),
+	}
+
+	/* the files are processed as a single log, so that csv gets a single header */
+	/* a newline ends the last line of every file, the empty lines are skipped */
+	readers := make([]io.Reader, 0, 2*flags.NArg())
+	for _, path := range flags.Args() {
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		readers = append(readers, file, strings.NewReader("\n"))
+	}
+	if len(readers) == 0 {
+		readers = append(readers, std.stdin)
+	}
+
+	stats, err := accesslog.Enrich(context.Background(), iniFile, io.MultiReader(readers...), std.stdout, opts...)
+	if err != nil {
+		return err
+	}
+
+	if !*quiet {
+		fmt.Fprintf(std.stderr, "%d lines, %d matched, %d invalid\n", stats.Lines, stats.Matched, stats.Invalid)
+	}
+	return nil
+}
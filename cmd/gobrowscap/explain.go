@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/tony2001/gobrowscap"
+)
+
+func runExplain(args []string, std streams) error {
+	flags, load := newFlagSet("explain", "user agent", std)
+	format := flags.String("format", "text", "output format: text or json")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return errUsage
+	}
+	/* allow the user agent to be passed without quoting */
+	userAgent := strings.Join(flags.Args(), " ")
+
+	switch *format {
+	case "text", "json":
+	default:
+		return fmt.Errorf("unknown format '%s'", *format)
+	}
+
+	iniFile, err := load.load()
+	if err != nil {
+		return err
+	}
+	defer iniFile.Close()
+
+	explanation := gobrowscap.Explain(iniFile, userAgent)
+
+	if *format == "json" {
+		encoder := json.NewEncoder(std.stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(explanation)
+	}
+	writeExplanation(std.stdout, explanation)
+	return nil
+}
+
+func writeExplanation(out io.Writer, explanation *gobrowscap.Explanation) {
+	fmt.Fprintf(out, "user agent: %s\n", explanation.UserAgent)
+
+	matchedBatches := 0
+	for _, batch := range explanation.Batches {
+		if batch.Matched {
+			matchedBatches++
+		}
+	}
+	fmt.Fprintf(out, "batches:    %d tried, %d matched\n", len(explanation.Batches), matchedBatches)
+	for _, batch := range explanation.Batches {
+		fmt.Fprintf(out, "  #%-6d matched=%-5t candidates=%v\n", batch.Index, batch.Matched, batch.Candidates)
+	}
+
+	if explanation.Match == nil {
+		fmt.Fprintf(out, "match:      none\n")
+		return
+	}
+
+	fmt.Fprintf(out, "match:      [%s] at position %d\n", explanation.Match.Section, explanation.Match.Position)
+	fmt.Fprintf(out, "parents:    %s\n", strings.Join(explanation.ParentChain, " -> "))
+
+	fmt.Fprintf(out, "properties:\n")
+	keys := make([]string, 0, len(explanation.Match.Browser.Properties))
+	for key := range explanation.Match.Browser.Properties {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fmt.Fprintf(out, "  %s=%s (from [%s])\n", key, explanation.Match.Browser.Properties[key], explanation.PropertySources[key])
+	}
+
+	fmt.Fprintf(out, "also matching:\n")
+	if len(explanation.Alternatives) == 0 {
+		fmt.Fprintf(out, "  none\n")
+	}
+	for _, alternative := range explanation.Alternatives {
+		fmt.Fprintf(out, "  [%s] at position %d\n", alternative.Section, alternative.Position)
+	}
+}
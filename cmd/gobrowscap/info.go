@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/tony2001/gobrowscap"
+)
+
+func runVersion(args []string, std streams) error {
+	flags, load := newFlagSet("version", "", std)
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	iniFile, err := load.load()
+	if err != nil {
+		return err
+	}
+	defer iniFile.Close()
+
+	metadata := gobrowscap.GetFileMetadata(iniFile)
+	fmt.Fprintf(std.stdout, "version:   %s\n", metadata.Version)
+	if !metadata.Released.IsZero() {
+		fmt.Fprintf(std.stdout, "released:  %s\n", metadata.Released.Format(time.RFC1123Z))
+	}
+	if metadata.Type != "" {
+		fmt.Fprintf(std.stdout, "type:      %s\n", metadata.Type)
+	}
+	fmt.Fprintf(std.stdout, "source:    %s\n", metadata.Source)
+	fmt.Fprintf(std.stdout, "load time: %s\n", metadata.LoadDuration)
+	return nil
+}
+
+func runStats(args []string, std streams) error {
+	flags, load := newFlagSet("stats", "", std)
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	iniFile, err := load.load()
+	if err != nil {
+		return err
+	}
+	defer iniFile.Close()
+
+	stats := gobrowscap.GetFileStats(iniFile)
+	fmt.Fprintf(std.stdout, "sections:   %d\n", stats.Sections)
+	fmt.Fprintf(std.stdout, "patterns:   %d\n", stats.Patterns)
+	fmt.Fprintf(std.stdout, "batches:    %d\n", stats.Batches)
+	fmt.Fprintf(std.stdout, "batch size: %d\n", stats.BatchSize)
+	return nil
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/tony2001/gobrowscap"
+)
+
+var lookupColumns = []string{
+	"UserAgent", "Browser", "Version", "Platform", "PlatformVersion",
+	"DeviceType", "IsMobileDevice", "IsTablet", "IsCrawler",
+}
+
+type lookupRecord struct {
+	UserAgent string
+	Browser   *gobrowscap.Browser `json:",omitempty"`
+	Error     string              `json:",omitempty"`
+}
+
+func runLookup(args []string, std streams) error {
+	flags, load := newFlagSet("lookup", "[user agent]", std)
+	format := flags.String("format", "table", "output format: table, json or csv")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	switch *format {
+	case "table", "json", "csv":
+	default:
+		return fmt.Errorf("unknown format '%s'", *format)
+	}
+
+	iniFile, err := load.load()
+	if err != nil {
+		return err
+	}
+	defer iniFile.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	/* the lines of stdin are looked up while they are read */
+	userAgents := make(chan string)
+	var readErr error
+	go func() {
+		defer close(userAgents)
+		if flags.NArg() > 0 {
+			/* the same way as explain, the user agent can be passed without quoting */
+			select {
+			case userAgents <- strings.Join(flags.Args(), " "):
+			case <-ctx.Done():
+			}
+			return
+		}
+		readErr = readLines(ctx, std.stdin, userAgents)
+	}()
+	results := gobrowscap.SearchBrowsersStream(ctx, iniFile, userAgents)
+
+	out := bufio.NewWriter(std.stdout)
+	switch *format {
+	case "json":
+		err = writeLookupJSON(out, results)
+	case "csv":
+		err = writeLookupCSV(out, results)
+	default:
+		err = writeLookupTable(out, results)
+	}
+	if err != nil {
+		return err
+	}
+	if err := out.Flush(); err != nil {
+		return err
+	}
+	/* the results are closed after userAgents, so readErr is set by now */
+	return readErr
+}
+
+/* sends the non-empty lines of reader to lines until ctx is done */
+func readLines(ctx context.Context, reader io.Reader, lines chan<- string) error {
+	scanner := bufio.NewScanner(reader)
+	/* user agents can be much longer than the default limit of 64k */
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		/* the files written on Windows end the lines with \r\n */
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		if line == "" {
+			continue
+		}
+		select {
+		case lines <- line:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return scanner.Err()
+}
+
+func lookupRow(result gobrowscap.BrowserResult) []string {
+	browser := result.Browser
+	if browser == nil {
+		browser = new(gobrowscap.Browser)
+	}
+	return []string{
+		result.UserAgent,
+		browser.Browser,
+		browser.Version,
+		browser.Platform,
+		browser.PlatformVersion,
+		browser.DeviceType,
+		strconv.FormatBool(browser.IsMobileDevice),
+		strconv.FormatBool(browser.IsTablet),
+		strconv.FormatBool(browser.IsCrawler),
+	}
+}
+
+/* the columns are aligned, so the table is only written once all the results are known */
+func writeLookupTable(out io.Writer, results <-chan gobrowscap.BrowserResult) error {
+	writer := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	writeTableRow(writer, lookupColumns)
+	for result := range results {
+		writeTableRow(writer, lookupRow(result))
+	}
+	return writer.Flush()
+}
+
+func writeTableRow(writer io.Writer, row []string) {
+	for i, value := range row {
+		if i > 0 {
+			fmt.Fprint(writer, "\t")
+		}
+		fmt.Fprint(writer, value)
+	}
+	fmt.Fprint(writer, "\n")
+}
+
+func writeLookupCSV(out io.Writer, results <-chan gobrowscap.BrowserResult) error {
+	writer := csv.NewWriter(out)
+	if err := writer.Write(lookupColumns); err != nil {
+		return err
+	}
+	for result := range results {
+		if err := writer.Write(lookupRow(result)); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+/* writes the same indented array as json.Encoder, one record at a time */
+func writeLookupJSON(out io.Writer, results <-chan gobrowscap.BrowserResult) error {
+	written := 0
+	for result := range results {
+		record := lookupRecord{UserAgent: result.UserAgent, Browser: result.Browser}
+		if result.Err != nil {
+			record.Error = result.Err.Error()
+		}
+
+		data, err := json.MarshalIndent(record, "  ", "  ")
+		if err != nil {
+			return err
+		}
+		separator := ",\n  "
+		if written == 0 {
+			separator = "[\n  "
+		}
+		if _, err := io.WriteString(out, separator); err != nil {
+			return err
+		}
+		if _, err := out.Write(data); err != nil {
+			return err
+		}
+		written++
+	}
+
+	end := "\n]\n"
+	if written == 0 {
+		end = "[]\n"
+	}
+	_, err := io.WriteString(out, end)
+	return err
+}
@@ -0,0 +1,150 @@
+// Command gobrowscap looks up user agents in browscap data and inspects the data itself.
+//
+// Usage:
+//
+//	gobrowscap lookup  -ini full_php_browscap.ini [-format table|json|csv] [user agent]
+//	gobrowscap explain -ini full_php_browscap.ini [-format text|json] user agent
+//	gobrowscap version -ini full_php_browscap.ini
+//	gobrowscap stats   -ini full_php_browscap.ini
+//	gobrowscap enrich  -ini full_php_browscap.ini [-log-format combined|common|json] [-output jsonl|csv|original] [log file...]
+//
+// lookup and explain join their arguments into a single user agent, so that it doesn't have to be quoted.
+// lookup reads the user agents from stdin, one per line, when none is given as arguments,
+// enrich reads the log from stdin when no files are given.
+// Every command accepts -snapshot instead of -ini to load a file saved with SaveSnapshot.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/tony2001/gobrowscap"
+)
+
+type command struct {
+	description string
+	run         func(args []string, std streams) error
+}
+
+/* the standard streams of the commands, replaced by the tests */
+type streams struct {
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+}
+
+var commands = map[string]command{
+	"lookup":  {"look up user agents given as arguments or on stdin", runLookup},
+	"explain": {"show how a user agent is matched", runExplain},
+	"version": {"print the version of the browscap data", runVersion},
+	"stats":   {"print the number of sections, patterns and batches", runStats},
+	"enrich":  {"annotate access logs with the detected browsers", runEnrich},
+}
+
+/* returned by the commands to exit without printing anything else */
+var (
+	errUsage = fmt.Errorf("usage error")
+	errHelp  = fmt.Errorf("help requested")
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "gobrowscap: unknown command '%s'\n", os.Args[1])
+		usage()
+		os.Exit(2)
+	}
+
+	if err := cmd.run(os.Args[2:], streams{stdin: os.Stdin, stdout: os.Stdout, stderr: os.Stderr}); err != nil {
+		if err == errHelp {
+			os.Exit(0)
+		}
+		if err == errUsage {
+			os.Exit(2)
+		}
+		fmt.Fprintf(os.Stderr, "gobrowscap %s: %v\n", os.Args[1], err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintf(os.Stderr, "usage: gobrowscap <command> [flags] [arguments]\n\ncommands:\n")
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-8s %s\n", name, commands[name].description)
+	}
+	fmt.Fprintf(os.Stderr, "\nrun 'gobrowscap <command> -h' for the flags of a command\n")
+}
+
+/* flags shared by all the commands selecting the browscap data */
+type loadFlags struct {
+	iniPath      string
+	snapshotPath string
+	batchSize    int
+	pureGo       bool
+	overlays     string
+}
+
+func newFlagSet(name string, usageArgs string, std streams) (*flag.FlagSet, *loadFlags) {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(std.stderr)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: gobrowscap %s [flags] %s\n", name, usageArgs)
+		flags.PrintDefaults()
+	}
+
+	load := new(loadFlags)
+	flags.StringVar(&load.iniPath, "ini", os.Getenv("BROWSCAP_INI"), "path to the browscap ini file, optionally compressed (default $BROWSCAP_INI)")
+	flags.StringVar(&load.snapshotPath, "snapshot", "", "path to a snapshot saved with SaveSnapshot, used instead of -ini")
+	flags.IntVar(&load.batchSize, "batch-size", 10, "number of patterns in a batch regex")
+	flags.BoolVar(&load.pureGo, "pure-go", false, "match with the Go regexp engine instead of libpcre")
+	flags.StringVar(&load.overlays, "overlays", "", "comma-separated paths of ini files overriding the -ini sections")
+	return flags, load
+}
+
+func parseFlags(flags *flag.FlagSet, args []string) error {
+	if err := flags.Parse(args); err != nil {
+		/* the error has already been printed along with the usage */
+		if err == flag.ErrHelp {
+			return errHelp
+		}
+		return errUsage
+	}
+	return nil
+}
+
+func (load *loadFlags) load() (*gobrowscap.IniFile, error) {
+	var opts []gobrowscap.Option
+	if load.pureGo {
+		opts = append(opts, gobrowscap.WithMatcher(gobrowscap.GoMatcher))
+	}
+	if load.overlays != "" {
+		opts = append(opts, gobrowscap.WithOverlays(strings.Split(load.overlays, ",")...))
+	}
+
+	var iniFile *gobrowscap.IniFile
+	var err error
+	switch {
+	case load.snapshotPath != "":
+		iniFile, err = gobrowscap.LoadSnapshot(load.snapshotPath, opts...)
+	case load.iniPath != "":
+		iniFile, err = gobrowscap.LoadIniFile(load.iniPath, load.batchSize, opts...)
+	default:
+		return nil, fmt.Errorf("either -ini or -snapshot is required")
+	}
+	return iniFile, err
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testIniFile      = "../../test-data/full_php_browscap.ini"
+	testChromeAgent  = "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_10_0) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/37.0.1062.110 Safari/537.36"
+	testIPhoneAgent  = "Mozilla/5.0 (iPhone; U; CPU iPhone OS 4_3_2 like Mac OS X; en-us) AppleWebKit/533.17.9 (KHTML, like Gecko) Version/5.0.2 Mobile/8H7 Safari/6533.18.5"
+	testAccessLogRow = `127.0.0.1 - - [10/Oct/2021:13:55:36 +0000] "GET / HTTP/1.1" 200 2326 "-" "` + testChromeAgent + `"`
+)
+
+func TestCommands(t *testing.T) {
+	t.Setenv("BROWSCAP_INI", "")
+
+	for _, test := range []struct {
+		name     string
+		command  string
+		args     []string
+		stdin    string
+		err      error
+		contains []string
+		lines    int /* of the output, if not zero */
+	}{
+		{
+			name:     "lookup joins the arguments",
+			command:  "lookup",
+			args:     append([]string{"-ini", testIniFile}, strings.Fields(testChromeAgent)...),
+			contains: []string{"Chrome", "MacOSX", "37.0"},
+			lines:    2,
+		},
+		{
+			name:     "lookup reads crlf lines from stdin",
+			command:  "lookup",
+			args:     []string{"-ini", testIniFile, "-format", "csv"},
+			stdin:    testChromeAgent + "\r\n" + testIPhoneAgent + "\r\n\r\n",
+			contains: []string{`"` + testChromeAgent + `",Chrome,37.0,MacOSX`, `"` + testIPhoneAgent + `",Safari,5.0,iOS`},
+			lines:    3,
+		},
+		{
+			name:     "explain joins the arguments",
+			command:  "explain",
+			args:     append([]string{"-ini", testIniFile}, strings.Fields(testChromeAgent)...),
+			contains: []string{"user agent: " + testChromeAgent + "\n", "Browser=Chrome", "parents:"},
+		},
+		{
+			name:     "version",
+			command:  "version",
+			args:     []string{"-ini", testIniFile},
+			contains: []string{"version:", "source:    " + testIniFile},
+		},
+		{
+			name:     "stats",
+			command:  "stats",
+			args:     []string{"-ini", testIniFile, "-batch-size", "3"},
+			contains: []string{"batch size: 3"},
+		},
+		{
+			name:     "enrich",
+			command:  "enrich",
+			args:     []string{"-ini", testIniFile, "-q"},
+			stdin:    testAccessLogRow + "\r\n" + "not a log line\n",
+			contains: []string{`"browser":"Chrome"`},
+			lines:    1,
+		},
+		{
+			name:    "explain requires a user agent",
+			command: "explain",
+			args:    []string{"-ini", testIniFile},
+			err:     errUsage,
+		},
+		{
+			name:    "unknown flag",
+			command: "lookup",
+			args:    []string{"-unknown"},
+			err:     errUsage,
+		},
+		{
+			name:    "help",
+			command: "stats",
+			args:    []string{"-h"},
+			err:     errHelp,
+		},
+	} {
+		var stdout, stderr bytes.Buffer
+		std := streams{stdin: strings.NewReader(test.stdin), stdout: &stdout, stderr: &stderr}
+
+		err := commands[test.command].run(test.args, std)
+		if test.err != nil {
+			assert.Equal(t, test.err, err, test.name)
+			continue
+		}
+		require.NoError(t, err, test.name)
+
+		for _, expected := range test.contains {
+			assert.Contains(t, stdout.String(), expected, test.name)
+		}
+		if test.lines > 0 {
+			assert.Equal(t, test.lines, strings.Count(stdout.String(), "\n"), test.name)
+		}
+	}
+}
+
+func TestCommandErrors(t *testing.T) {
+	t.Setenv("BROWSCAP_INI", "")
+
+	for _, test := range []struct {
+		command string
+		args    []string
+		err     string
+	}{
+		{"lookup", []string{"-format", "xml", "ua"}, "unknown format 'xml'"},
+		{"explain", []string{"-format", "xml", "ua"}, "unknown format 'xml'"},
+		{"lookup", []string{"ua"}, "either -ini or -snapshot is required"},
+		{"version", []string{"-ini", "../../test-data/missing.ini"}, "no such file or directory"},
+		{"enrich", []string{"-ini", testIniFile, "-log-format", "xml"}, "xml"},
+	} {
+		var stdout, stderr bytes.Buffer
+		std := streams{stdin: strings.NewReader(""), stdout: &stdout, stderr: &stderr}
+
+		err := commands[test.command].run(test.args, std)
+		require.Error(t, err, test.command)
+		assert.Contains(t, err.Error(), test.err, test.command)
+	}
+}
+
+func TestEnrichFiles(t *testing.T) {
+	/* the last line of the first file is not glued to the first line of the next one */
+	dir := t.TempDir()
+	paths := []string{filepath.Join(dir, "access.log.1"), filepath.Join(dir, "access.log")}
+	require.NoError(t, ioutil.WriteFile(paths[0], []byte(testAccessLogRow), 0644))
+	require.NoError(t, ioutil.WriteFile(paths[1], []byte(testAccessLogRow+"\n"), 0644))
+
+	var stdout, stderr bytes.Buffer
+	std := streams{stdin: strings.NewReader(""), stdout: &stdout, stderr: &stderr}
+	require.NoError(t, runEnrich(append([]string{"-ini", testIniFile, "-output", "csv"}, paths...), std))
+
+	assert.Equal(t, 3, strings.Count(stdout.String(), "\n"))
+	assert.Equal(t, 2, strings.Count(stdout.String(), ",Chrome,"))
+	assert.Equal(t, "2 lines, 2 matched, 0 invalid\n", stderr.String())
+}
+
+func TestLookupJSON(t *testing.T) {
+	/* the records are written one by one, the same way json.Encoder writes the array */
+	for _, stdin := range []string{"", testChromeAgent + "\nUnknown/1.0\n" + testIPhoneAgent + "\n"} {
+		var stdout bytes.Buffer
+		std := streams{stdin: strings.NewReader(stdin), stdout: &stdout, stderr: &bytes.Buffer{}}
+		require.NoError(t, runLookup([]string{"-ini", testIniFile, "-format", "json"}, std))
+
+		records := make([]lookupRecord, 0)
+		require.NoError(t, json.Unmarshal(stdout.Bytes(), &records))
+		assert.Len(t, records, strings.Count(stdin, "\n"))
+
+		var expected bytes.Buffer
+		encoder := json.NewEncoder(&expected)
+		encoder.SetIndent("", "  ")
+		require.NoError(t, encoder.Encode(records))
+		assert.Equal(t, expected.String(), stdout.String())
+	}
+}
+
+func TestExplainJSON(t *testing.T) {
+	var stdout bytes.Buffer
+	std := streams{stdin: strings.NewReader(""), stdout: &stdout, stderr: &bytes.Buffer{}}
+	require.NoError(t, runExplain([]string{"-ini", testIniFile, "-format", "json", testIPhoneAgent}, std))
+
+	var explanation struct {
+		UserAgent string
+		Match     struct {
+			Browser struct {
+				Browser  string
+				Platform string
+			}
+		}
+	}
+	require.NoError(t, json.Unmarshal(stdout.Bytes(), &explanation))
+	assert.Equal(t, testIPhoneAgent, explanation.UserAgent)
+	assert.Equal(t, "Safari", explanation.Match.Browser.Browser)
+	assert.Equal(t, "iOS", explanation.Match.Browser.Platform)
+}
@@ -0,0 +1,77 @@
+package gobrowscap
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+)
+
+var (
+	gzipMagic  = []byte{0x1f, 0x8b}
+	bzip2Magic = []byte{'B', 'Z', 'h'}
+	zipMagic   = []byte{'P', 'K', 0x03, 0x04}
+)
+
+// sniff the first bytes of the stream and unwrap gzip, bzip2 and single-entry zip data,
+// plain data is returned as is
+func decompressReader(reader io.Reader) (io.Reader, io.Closer, error) {
+	buf := bufio.NewReader(reader)
+
+	magic, err := buf.Peek(len(zipMagic))
+	if err != nil && err != io.EOF {
+		return nil, nil, err
+	}
+
+	switch {
+	case bytes.HasPrefix(magic, gzipMagic):
+		gzReader, err := gzip.NewReader(buf)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to open gzip stream: %w", err)
+		}
+		return gzReader, gzReader, nil
+	case bytes.HasPrefix(magic, bzip2Magic):
+		return bzip2.NewReader(buf), nil, nil
+	case bytes.HasPrefix(magic, zipMagic):
+		return openZipEntry(buf)
+	}
+	return buf, nil, nil
+}
+
+func openZipEntry(reader io.Reader) (io.Reader, io.Closer, error) {
+	/* zip keeps its directory at the end of the archive, so it has to be read in full */
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open zip archive: %w", err)
+	}
+
+	var entry *zip.File
+	for _, file := range archive.File {
+		if file.FileInfo().IsDir() {
+			continue
+		}
+		if entry != nil {
+			return nil, nil, fmt.Errorf("zip archive must contain a single file, found '%s' and '%s'", entry.Name, file.Name)
+		}
+		entry = file
+	}
+
+	if entry == nil {
+		return nil, nil, fmt.Errorf("zip archive contains no files")
+	}
+
+	entryReader, err := entry.Open()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open zip entry '%s': %w", entry.Name, err)
+	}
+	return entryReader, entryReader, nil
+}
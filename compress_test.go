@@ -0,0 +1,45 @@
+package gobrowscap
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadIniReaderCompressed(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+
+	data, err := ioutil.ReadFile(TEST_INI_FILE)
+	require.NoError(t, err)
+
+	var gzipped bytes.Buffer
+	gzWriter := gzip.NewWriter(&gzipped)
+	_, err = gzWriter.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, gzWriter.Close())
+
+	var zipped bytes.Buffer
+	zipWriter := zip.NewWriter(&zipped)
+	entry, err := zipWriter.Create("full_php_browscap.ini")
+	require.NoError(t, err)
+	_, err = entry.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, zipWriter.Close())
+
+	for name, compressed := range map[string][]byte{"gzip": gzipped.Bytes(), "zip": zipped.Bytes()} {
+		iniFile, err := LoadIniReader(bytes.NewReader(compressed), 10)
+		require.NoError(t, err, name)
+		assert.Equal(t, GetFileVersion(FILE), GetFileVersion(iniFile), name)
+
+		browser, err := SearchBrowser(iniFile, TEST_IPHONE_AGENT)
+		require.NoError(t, err, name)
+		assert.Equal(t, "Safari", browser.Browser, name)
+	}
+}
@@ -0,0 +1,176 @@
+package gobrowscap
+
+import (
+	"context"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Detector searches browsers in an IniFile that can be replaced at any time,
+// the searches running during a reload keep using the previous IniFile.
+type Detector struct {
+	iniFile  atomic.Value /* *IniFile */
+	reloadMu sync.Mutex
+	onReload func(version string)
+	onError  func(err error)
+	cache    *Cache
+	added    []addedPattern /* added with AddPattern to the current IniFile */
+}
+
+// DetectorOption configures a Detector.
+type DetectorOption func(*Detector)
+
+// OnReload sets a callback called with the version of the new IniFile after every successful reload.
+// The callbacks are called after the reload is finished, so they may reload the detector or add patterns.
+func OnReload(callback func(version string)) DetectorOption {
+	return func(d *Detector) {
+		d.onReload = callback
+	}
+}
+
+// OnReloadError sets a callback called with the error of every failed reload.
+func OnReloadError(callback func(err error)) DetectorOption {
+	return func(d *Detector) {
+		d.onError = callback
+	}
+}
+
+// WithCache makes the detector cache up to size search results for ttl each, see NewCache.
+// The cache is purged whenever the IniFile is replaced.
+func WithCache(size int, ttl time.Duration) DetectorOption {
+	return func(d *Detector) {
+		d.cache = NewCache(size, ttl)
+	}
+}
+
+// NewDetector creates a Detector serving searches from iniFile.
+func NewDetector(iniFile *IniFile, opts ...DetectorOption) *Detector {
+	d := new(Detector)
+	for _, opt := range opts {
+		opt(d)
+	}
+	d.iniFile.Store(iniFile)
+	if d.cache != nil {
+		d.cache.bind(iniFile)
+	}
+	return d
+}
+
+// IniFile returns the IniFile currently used for the searches.
+func (d *Detector) IniFile() *IniFile {
+	return d.iniFile.Load().(*IniFile)
+}
+
+// Version returns the version of the IniFile currently used for the searches.
+func (d *Detector) Version() string {
+	return GetFileVersion(d.IniFile())
+}
+
+// Search is SearchBrowser using the current IniFile and the cache, if it's enabled.
+func (d *Detector) Search(userAgent string) (*Browser, error) {
+	return d.SearchContext(context.Background(), userAgent)
+}
+
+// SearchContext is Search respecting the cancellation of ctx, see SearchBrowserContext.
+func (d *Detector) SearchContext(ctx context.Context, userAgent string) (*Browser, error) {
+	if d.cache != nil {
+		return d.cache.SearchContext(ctx, d.IniFile(), userAgent)
+	}
+	return SearchBrowserContext(ctx, d.IniFile(), userAgent)
+}
+
+// Cache returns the detector cache or nil if it was created without WithCache.
+func (d *Detector) Cache() *Cache {
+	return d.cache
+}
+
+// Replace makes the detector use iniFile for all the following searches.
+// The patterns added with AddPattern are dropped.
+func (d *Detector) Replace(iniFile *IniFile) {
+	d.reloadMu.Lock()
+	d.added = nil
+	d.replace(iniFile)
+	d.reloadMu.Unlock()
+
+	d.reloaded(iniFile)
+}
+
+// Reload loads the browscap file at path with the format, batch size and options of the current
+// IniFile and replaces the current IniFile with it. The current IniFile is kept if loading fails.
+func (d *Detector) Reload(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		d.reloadFailed(err)
+		return err
+	}
+	defer file.Close()
+
+	return d.reloadFrom(file, path)
+}
+
+// ReloadFrom is Reload reading the browscap data from reader.
+func (d *Detector) ReloadFrom(reader io.Reader) error {
+	return d.reloadFrom(reader, "")
+}
+
+/* the callbacks are called without holding the lock, so that they can reload or add patterns */
+func (d *Detector) reloadFrom(reader io.Reader, source string) error {
+	iniFile, errs, err := d.loadAndReplace(reader, source)
+	if err != nil {
+		d.reloadFailed(err)
+		return err
+	}
+
+	d.reloaded(iniFile)
+	for _, err := range errs {
+		d.reloadFailed(err)
+	}
+	return nil
+}
+
+/* returns the new IniFile and the errors of the added patterns that were dropped */
+func (d *Detector) loadAndReplace(reader io.Reader, source string) (*IniFile, []error, error) {
+	/* serialize the reloads, so that an older file never replaces a newer one */
+	d.reloadMu.Lock()
+	defer d.reloadMu.Unlock()
+
+	current := d.IniFile()
+	var iniFile *IniFile
+	var err error
+	if current.parse != nil {
+		iniFile, err = loadReader(reader, current.batchSize, current.options, current.parse)
+	} else {
+		iniFile, err = readSnapshot(reader, current.options)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	iniFile.source = source
+
+	iniFile, errs := d.addPatternsAgain(iniFile)
+	d.replace(iniFile)
+	return iniFile, errs, nil
+}
+
+/* must be called with reloadMu held */
+func (d *Detector) replace(iniFile *IniFile) {
+	d.iniFile.Store(iniFile)
+	if d.cache != nil {
+		d.cache.bind(iniFile)
+	}
+}
+
+func (d *Detector) reloaded(iniFile *IniFile) {
+	if d.onReload != nil {
+		d.onReload(GetFileVersion(iniFile))
+	}
+}
+
+func (d *Detector) reloadFailed(err error) {
+	if d.onError != nil {
+		d.onError(err)
+	}
+}
@@ -0,0 +1,82 @@
+package gobrowscap
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetectorReload(t *testing.T) {
+	var versions []string
+	var reloadErrors []error
+	detector := NewDetector(FILE,
+		OnReload(func(version string) { versions = append(versions, version) }),
+		OnReloadError(func(err error) { reloadErrors = append(reloadErrors, err) }))
+	assert.Equal(t, GetFileVersion(FILE), detector.Version())
+
+	require.NoError(t, detector.ReloadFrom(strings.NewReader(TEST_SMALL_INI)))
+	assert.Equal(t, []string{"1"}, versions)
+
+	browser, err := detector.Search("TestBrowser/1.5")
+	require.NoError(t, err)
+	assert.Equal(t, "Test Browser", browser.Browser)
+
+	require.Error(t, detector.ReloadFrom(strings.NewReader("[Broken]\nisTablet=maybe\n")))
+	require.Error(t, detector.Reload("test-data/missing.ini"))
+	assert.Len(t, reloadErrors, 2)
+	assert.Equal(t, "1", detector.Version())
+
+	detector.Replace(FILE)
+	assert.Equal(t, GetFileVersion(FILE), detector.Version())
+
+	/* the callbacks may use the detector */
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		var detector *Detector
+		added := false
+		detector = NewDetector(FILE,
+			OnReload(func(version string) {
+				/* AddPattern calls the callback as well */
+				if version == "1" && !added {
+					added = true
+					assert.NoError(t, detector.AddPattern("Callback/*", nil, ""))
+				}
+			}),
+			OnReloadError(func(err error) {
+				assert.NoError(t, detector.ReloadFrom(strings.NewReader(TEST_SMALL_INI)))
+			}))
+		assert.Error(t, detector.ReloadFrom(strings.NewReader("[Broken]\nisTablet=maybe\n")))
+		browser, err := detector.Search("Callback/1.0")
+		if assert.NoError(t, err) {
+			assert.Equal(t, "callback/.*", browser.Pattern)
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the callbacks deadlocked")
+	}
+
+	/* the data is reloaded in the format it was loaded from */
+	csvFile, err := LoadCSVReader(strings.NewReader(TEST_SMALL_CSV), 10)
+	require.NoError(t, err)
+	detector = NewDetector(csvFile)
+	require.NoError(t, detector.ReloadFrom(strings.NewReader(TEST_SMALL_CSV)))
+	require.Error(t, detector.ReloadFrom(strings.NewReader(TEST_SMALL_INI)))
+
+	var snapshot bytes.Buffer
+	require.NoError(t, WriteSnapshot(csvFile, &snapshot))
+	snapshotFile, err := ReadSnapshot(bytes.NewReader(snapshot.Bytes()))
+	require.NoError(t, err)
+	detector = NewDetector(snapshotFile)
+	require.NoError(t, detector.ReloadFrom(bytes.NewReader(snapshot.Bytes())))
+	browser, err = detector.Search("TestBrowser/1.5")
+	require.NoError(t, err)
+	assert.Equal(t, "Test Browser", browser.Browser)
+	require.Error(t, detector.ReloadFrom(strings.NewReader(TEST_SMALL_CSV)))
+}
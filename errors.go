@@ -0,0 +1,95 @@
+package gobrowscap
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ParseErrorKind classifies the problems found while parsing browscap data.
+type ParseErrorKind int
+
+const (
+	// ParseErrorSyntax is reported for lines that are neither sections, comments nor key=value pairs.
+	ParseErrorSyntax ParseErrorKind = iota + 1
+	// ParseErrorInvalidBool is reported for boolean properties with values other than true/false.
+	ParseErrorInvalidBool
+	// ParseErrorUnknownParent is reported when Parent refers to a section that does not exist.
+	ParseErrorUnknownParent
+	// ParseErrorDuplicateSection is reported when a section name is used more than once.
+	ParseErrorDuplicateSection
+)
+
+func (kind ParseErrorKind) String() string {
+	switch kind {
+	case ParseErrorSyntax:
+		return "syntax error"
+	case ParseErrorInvalidBool:
+		return "invalid boolean value"
+	case ParseErrorUnknownParent:
+		return "unknown parent"
+	case ParseErrorDuplicateSection:
+		return "duplicate section"
+	}
+	return fmt.Sprintf("ParseErrorKind(%d)", int(kind))
+}
+
+// ParseError describes a single problem found in browscap data.
+type ParseError struct {
+	File    string /* the path of the overlay, empty for the main data */
+	Line    int
+	Section string
+	Key     string
+	Value   string
+	Kind    ParseErrorKind
+}
+
+func (e *ParseError) Error() string {
+	if e.File != "" {
+		return fmt.Sprintf("%s: %s", e.File, e.message())
+	}
+	return e.message()
+}
+
+func (e *ParseError) message() string {
+	switch e.Kind {
+	case ParseErrorInvalidBool:
+		return fmt.Sprintf("invalid value for %s: expected true/false, got '%s' on line %d", e.Key, e.Value, e.Line)
+	case ParseErrorUnknownParent:
+		return fmt.Sprintf("unknown Parent value specified (not present in the section names): '%s' in section '%s' on line %d", e.Value, e.Section, e.Line)
+	case ParseErrorDuplicateSection:
+		return fmt.Sprintf("duplicate section name '%s' on line %d", e.Section, e.Line)
+	}
+	return fmt.Sprintf("%s: '%s' on line %d", e.Kind, e.Value, e.Line)
+}
+
+// ParseErrors is returned instead of the first ParseError when loading with WithAllParseErrors.
+type ParseErrors []*ParseError
+
+func (e ParseErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	return fmt.Sprintf("%d parse errors: %s", len(e), strings.Join(messages, "; "))
+}
+
+type parseErrorCollector struct {
+	collectAll bool
+	errors     ParseErrors
+}
+
+/* returns the error back when the parsing has to be aborted */
+func (c *parseErrorCollector) add(err *ParseError) error {
+	if !c.collectAll {
+		return err
+	}
+	c.errors = append(c.errors, err)
+	return nil
+}
+
+func (c *parseErrorCollector) err() error {
+	if len(c.errors) > 0 {
+		return c.errors
+	}
+	return nil
+}
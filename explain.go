@@ -0,0 +1,101 @@
+package gobrowscap
+
+// PatternMatch is a pattern matching a user agent.
+type PatternMatch struct {
+	// Pattern is the regex the pattern is compiled from, the same as Browser.Pattern.
+	Pattern string
+	// Position is the index of the pattern in the search order.
+	Position int
+	// Section is the name of the section matched by the pattern.
+	Section string
+	Browser *Browser
+}
+
+// BatchTrace describes a batch regex tried by the search.
+type BatchTrace struct {
+	Index int
+	// Candidates are the positions of the patterns in the batch that the token index
+	// did not rule out for the user agent.
+	Candidates []int
+	Matched    bool
+}
+
+// Explanation describes how a user agent is matched, see Explain.
+type Explanation struct {
+	UserAgent string
+	// Match is the pattern SearchBrowser would return, nil when nothing matches.
+	Match *PatternMatch
+	// Alternatives are the other patterns matching the user agent, in the search order.
+	Alternatives []PatternMatch
+	Batches      []BatchTrace
+	// ParentChain holds the names of the matched section and its parents up to the root.
+	ParentChain []string
+	// PropertySources maps every property of the result to the name of the section supplying it.
+	PropertySources map[string]string
+}
+
+// Explain matches userAgent against all the candidate patterns and reports which pattern wins,
+// which ones match as well, the batches tried and where the properties of the result come from.
+// It's meant for debugging and is considerably slower than SearchBrowser.
+func Explain(iniFile *IniFile, userAgent string) *Explanation {
+	explanation := new(Explanation)
+	explanation.UserAgent = userAgent
+	explanation.PropertySources = make(map[string]string)
+
+	candidates := candidateBatches(iniFile, userAgent)
+	matches, foundPositions := matchAll(iniFile, candidates, userAgent)
+
+	matched := make(map[int]bool, len(foundPositions))
+	for _, position := range foundPositions {
+		matched[position] = true
+	}
+	for position, candidate := range candidates {
+		explanation.Batches = append(explanation.Batches, BatchTrace{
+			Index:      candidate.batch.index,
+			Candidates: candidate.patterns,
+			Matched:    matched[position],
+		})
+	}
+
+	for _, match := range matches {
+		patternMatch := newPatternMatch(iniFile, match)
+		if explanation.Match == nil {
+			explanation.Match = &patternMatch
+			continue
+		}
+		explanation.Alternatives = append(explanation.Alternatives, patternMatch)
+	}
+
+	if len(matches) == 0 {
+		return explanation
+	}
+
+	/* walk the parents the same way mergeProperties does, an empty value is replaced by the next one */
+	values := make(map[string]string)
+	section := iniFile.sections[matches[0].key]
+	for {
+		explanation.ParentChain = append(explanation.ParentChain, section.name)
+		for _, property := range section.properties {
+			if value, ok := values[property.key]; !ok || value == "" {
+				values[property.key] = property.value
+				explanation.PropertySources[property.key] = section.name
+			}
+		}
+
+		if section.parentName == "" {
+			break
+		}
+		section = iniFile.sections[section.parent]
+	}
+	return explanation
+}
+
+func newPatternMatch(iniFile *IniFile, match patternMatch) PatternMatch {
+	pattern := iniFile.patterns[match.patternIndex]
+	return PatternMatch{
+		Pattern:  pattern.patternStr,
+		Position: match.patternIndex,
+		Section:  iniFile.sections[match.key].name,
+		Browser:  resolveBrowser(iniFile, pattern, match.key),
+	}
+}
@@ -0,0 +1,73 @@
+package gobrowscap
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExplain(t *testing.T) {
+	iniFile, err := LoadIniReader(strings.NewReader(TEST_SMALL_INI), 10)
+	require.NoError(t, err)
+
+	explanation := Explain(iniFile, "TestBrowser/1.5")
+	require.NotNil(t, explanation.Match)
+	assert.Equal(t, "TestBrowser/1.*", explanation.Match.Section)
+	assert.Equal(t, []string{"TestBrowser/1.*", "Test Browser", "DefaultProperties"}, explanation.ParentChain)
+	assert.Equal(t, "Test Browser", explanation.PropertySources["Browser"])
+	assert.Equal(t, "Test Browser", explanation.PropertySources["Comment"])
+	assert.Equal(t, "TestBrowser/1.*", explanation.PropertySources["Version"])
+	require.NotEmpty(t, explanation.Batches)
+	assert.True(t, explanation.Batches[0].Matched)
+
+	browser, err := SearchBrowser(iniFile, "TestBrowser/1.5")
+	require.NoError(t, err)
+	assert.Equal(t, browser, explanation.Match.Browser)
+
+	explanation = Explain(iniFile, "Unknown/1.0")
+	assert.Nil(t, explanation.Match)
+	assert.Empty(t, explanation.ParentChain)
+
+	/* the empty values have sources as well */
+	iniFile, err = LoadIniReader(strings.NewReader(`
+[GJK_Browscap_Version]
+Version=1
+
+[DefaultProperties]
+Browser="DefaultProperties"
+Platform=""
+
+[EmptyApp/*]
+Parent="DefaultProperties"
+Browser=""
+Device_Name=""
+`), 10)
+	require.NoError(t, err)
+	explanation = Explain(iniFile, "EmptyApp/1.0")
+	require.NotNil(t, explanation.Match)
+	assert.Equal(t, map[string]string{
+		"Parent":      "EmptyApp/*",
+		"Browser":     "DefaultProperties",
+		"Platform":    "DefaultProperties",
+		"Device_Name": "EmptyApp/*",
+	}, explanation.PropertySources)
+	for key := range explanation.Match.Browser.Properties {
+		assert.Contains(t, explanation.PropertySources, key)
+	}
+
+	if testing.Short() {
+		return
+	}
+
+	explanation = Explain(FILE, TEST_USER_AGENT)
+	require.NotNil(t, explanation.Match)
+	browser, err = SearchBrowser(FILE, TEST_USER_AGENT)
+	require.NoError(t, err)
+	assert.Equal(t, browser, explanation.Match.Browser)
+	assert.Equal(t, explanation.Match.Section, explanation.ParentChain[0])
+	for _, alternative := range explanation.Alternatives {
+		assert.Greater(t, alternative.Position, explanation.Match.Position)
+	}
+}
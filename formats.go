@@ -0,0 +1,278 @@
+package gobrowscap
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+)
+
+/* the browscap.csv columns that are not section properties */
+const csvNameColumn = "PropertyName"
+
+var csvSkippedColumns = map[string]bool{
+	csvNameColumn:  true,
+	"AgentID":      true,
+	"MasterParent": true,
+	"LiteMode":     true,
+}
+
+// LoadCSVFile loads and indexes the browscap.csv file located at path.
+func LoadCSVFile(path string, batchSize int, opts ...Option) (*IniFile, error) {
+	return loadFile(path, batchSize, newOptions(opts), parseCSV)
+}
+
+// LoadCSVReader loads and indexes browscap.csv data read from reader, see LoadIniReader.
+func LoadCSVReader(reader io.Reader, batchSize int, opts ...Option) (*IniFile, error) {
+	return loadReader(reader, batchSize, newOptions(opts), parseCSV)
+}
+
+// LoadJSONFile loads and indexes the browscap.json file located at path.
+func LoadJSONFile(path string, batchSize int, opts ...Option) (*IniFile, error) {
+	return loadFile(path, batchSize, newOptions(opts), parseJSON)
+}
+
+// LoadJSONReader loads and indexes browscap.json data read from reader, see LoadIniReader.
+func LoadJSONReader(reader io.Reader, batchSize int, opts ...Option) (*IniFile, error) {
+	return loadReader(reader, batchSize, newOptions(opts), parseJSON)
+}
+
+/*
+browscap.csv starts with the version section as a header and a row, followed by the header of
+the sections and a row per section. Unlike the ini file, every row holds all the inherited properties,
+so the Comment equal to the parent one is dropped to tell the patterns from their parents the same way.
+*/
+func parseCSV(reader io.Reader, builder *sectionBuilder) error {
+	csvReader := csv.NewReader(reader)
+	csvReader.FieldsPerRecord = -1
+	csvReader.LazyQuotes = true
+
+	versionHeader, err := csvReader.Read()
+	if err != nil {
+		return fmt.Errorf("failed to read the version header: %w", err)
+	}
+	versionRow, err := csvReader.Read()
+	if err != nil {
+		return fmt.Errorf("failed to read the version row: %w", err)
+	}
+	header, err := csvReader.Read()
+	if err != nil {
+		return fmt.Errorf("failed to read the header: %w", err)
+	}
+
+	nameColumn := -1
+	for i, column := range header {
+		if column == csvNameColumn {
+			nameColumn = i
+		}
+	}
+	if nameColumn < 0 {
+		return fmt.Errorf("no %s column in the header", csvNameColumn)
+	}
+
+	/* the version header repeats the section name, the properties are known by their position */
+	versionKeys := []string{versionKey, releasedKey}
+	if len(versionHeader) > 0 && versionHeader[0] == versionSection {
+		for i, value := range versionRow {
+			if i < len(versionKeys) {
+				builder.addProperty(nil, versionKeys[i], value, 2)
+			}
+		}
+	}
+
+	comments := make(map[string]string)
+	csvReader.ReuseRecord = true
+	for {
+		row, err := csvReader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		lineNum, _ := csvReader.FieldPos(0)
+
+		if len(row) != len(header) {
+			err := builder.addError(&ParseError{Line: lineNum, Value: fmt.Sprintf("%d columns, expected %d", len(row), len(header)), Kind: ParseErrorSyntax})
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		name := row[nameColumn]
+		section, err := builder.addSection(name, lineNum)
+		if err != nil {
+			return err
+		}
+		if section == nil {
+			continue
+		}
+
+		parentName, comment := "", ""
+		for i, column := range header {
+			switch column {
+			case "Parent":
+				parentName = row[i]
+			case "Comment":
+				comment = row[i]
+			}
+		}
+		comments[name] = comment
+
+		for i, value := range row {
+			key := header[i]
+			if csvSkippedColumns[key] || value == "" {
+				continue
+			}
+			if key == "Comment" && parentName != "" && comments[parentName] == value {
+				continue
+			}
+
+			if err := builder.addProperty(section, key, value, lineNum); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+/* remembers the offsets of the newlines to report the lines of the offsets */
+type lineCounter struct {
+	reader   io.Reader
+	offset   int64
+	newlines []int64
+}
+
+func (c *lineCounter) Read(p []byte) (int, error) {
+	n, err := c.reader.Read(p)
+	for i := 0; i < n; i++ {
+		if p[i] == '\n' {
+			c.newlines = append(c.newlines, c.offset+int64(i))
+		}
+	}
+	c.offset += int64(n)
+	return n, err
+}
+
+func (c *lineCounter) line(offset int64) int {
+	return sort.Search(len(c.newlines), func(i int) bool { return c.newlines[i] >= offset }) + 1
+}
+
+/*
+browscap.json is an object holding the comments, the version section and the sections
+in the same order and with the same properties as the ini file. The properties are reported
+on the line of their section, since the bodies of the sections are usually encoded as strings.
+*/
+func parseJSON(reader io.Reader, builder *sectionBuilder) error {
+	counter := &lineCounter{reader: reader}
+	decoder := json.NewDecoder(counter)
+	decoder.UseNumber()
+
+	if err := expectDelim(decoder, '{'); err != nil {
+		return err
+	}
+
+	/* walk the tokens instead of decoding maps to keep the order of the sections */
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+		name := token.(string)
+		lineNum := counter.line(decoder.InputOffset())
+
+		if name == "comments" {
+			var comments interface{}
+			if err := decoder.Decode(&comments); err != nil {
+				return err
+			}
+			continue
+		}
+
+		section, err := builder.addSection(name, lineNum)
+		if err != nil {
+			return err
+		}
+
+		/* the published file stores the section bodies as JSON encoded strings */
+		var body json.RawMessage
+		if err := decoder.Decode(&body); err != nil {
+			return err
+		}
+		if len(body) > 0 && body[0] == '"' {
+			var str string
+			if err := json.Unmarshal(body, &str); err != nil {
+				return err
+			}
+			body = json.RawMessage(str)
+		}
+		if err := parseJSONSection(body, builder, section, name, lineNum); err != nil {
+			return err
+		}
+	}
+
+	if err := expectDelim(decoder, '}'); err != nil {
+		return err
+	}
+	return nil
+}
+
+/* the properties are walked as tokens as well, the order of the keys matters for the parse errors */
+func parseJSONSection(body []byte, builder *sectionBuilder, section *IniSection, name string, lineNum int) error {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+
+	if err := expectDelim(decoder, '{'); err != nil {
+		return fmt.Errorf("section '%s' on line %d: %w", name, lineNum, err)
+	}
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+		key := token.(string)
+
+		var value interface{}
+		if err := decoder.Decode(&value); err != nil {
+			return err
+		}
+
+		var str string
+		switch value := value.(type) {
+		case string:
+			str = value
+		case bool:
+			str = strconv.FormatBool(value)
+		case json.Number:
+			str = value.String()
+		case nil:
+		default:
+			err := builder.addError(&ParseError{Line: lineNum, Section: name, Key: key, Value: fmt.Sprintf("%v", value), Kind: ParseErrorSyntax})
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := builder.addProperty(section, key, str, lineNum); err != nil {
+			return err
+		}
+	}
+	return expectDelim(decoder, '}')
+}
+
+func expectDelim(decoder *json.Decoder, delim json.Delim) error {
+	token, err := decoder.Token()
+	if err != nil {
+		return err
+	}
+	if token != delim {
+		return fmt.Errorf("expected '%s', got '%v'", delim, token)
+	}
+	return nil
+}
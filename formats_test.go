@@ -0,0 +1,103 @@
+package gobrowscap
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const TEST_SMALL_CSV = `"GJK_Browscap_Version","GJK_Browscap_Version"
+"1","Thu, 07 Oct 2021 10:48:05 +0000"
+"PropertyName","AgentID","MasterParent","LiteMode","Parent","Comment","Browser","Version"
+"DefaultProperties","1","true","true","","DefaultProperties","DefaultProperties",""
+"Test Browser","2","true","true","DefaultProperties","Test Browser","Test Browser",""
+"TestBrowser/1.*","3","false","true","Test Browser","Test Browser","Test Browser","1.0"
+`
+
+const TEST_SMALL_JSON = `{
+	"comments": ["Provided courtesy of https://browscap.org/"],
+	"GJK_Browscap_Version": {"Version": "1", "Released": "Thu, 07 Oct 2021 10:48:05 +0000"},
+	"DefaultProperties": {"Comment": "DefaultProperties", "Browser": "DefaultProperties"},
+	"Test Browser": {"Parent": "DefaultProperties", "Comment": "Test Browser", "Browser": "Test Browser"},
+	"TestBrowser/1.*": {"Parent": "Test Browser", "Version": "1.0", "isMobileDevice": false}
+}`
+
+/* an excerpt of the published browscap.json, the section bodies are JSON encoded strings */
+const TEST_BROWSCAP_JSON = `{
+    "comments": [
+        "Provided courtesy of https:\/\/browscap.org\/",
+        "Created on Thursday, October 7, 2021 at 10:48 AM UTC",
+        "Keep up with the latest goings-on with the project:",
+        "Follow us on Twitter <https:\/\/twitter.com\/browscap>, or...",
+        "Like us on Facebook <https:\/\/facebook.com\/browscap>, or...",
+        "Collaborate on GitHub <https:\/\/github.com\/browscap>, or...",
+        "Discuss on Google Groups <https:\/\/groups.google.com\/forum\/#!forum\/browscap>."
+    ],
+    "GJK_Browscap_Version": {
+        "Version": "6000045",
+        "Released": "Thu, 07 Oct 2021 10:48:05 +0000",
+        "Format": "json",
+        "Type": "FULL"
+    },
+    "DefaultProperties": "{\"Comment\":\"DefaultProperties\",\"Browser\":\"DefaultProperties\",\"Browser_Type\":\"unknown\",\"Version\":\"0.0\",\"MajorVer\":\"0\",\"MinorVer\":\"0\",\"Platform\":\"unknown\",\"isMobileDevice\":false,\"isTablet\":false,\"Crawler\":false,\"Device_Type\":\"unknown\"}",
+    "Chrome 94.0": "{\"Parent\":\"DefaultProperties\",\"Comment\":\"Chrome 94.0\",\"Browser\":\"Chrome\",\"Browser_Type\":\"Browser\",\"Browser_Maker\":\"Google Inc\",\"Version\":\"94.0\",\"MajorVer\":\"94\",\"MinorVer\":\"0\"}",
+    "Mozilla\/5.0 (*Windows NT 10.0*Win64? x64*) applewebkit* (khtml* like gecko) Chrome\/94.0* Safari*": "{\"Parent\":\"Chrome 94.0\",\"Platform\":\"Win10\",\"Platform_Version\":\"10.0\",\"Device_Type\":\"Desktop\"}"
+}`
+
+func TestLoadCSVAndJSON(t *testing.T) {
+	iniFile, err := LoadIniReader(strings.NewReader(TEST_SMALL_INI), 10)
+	require.NoError(t, err)
+	expected, err := SearchBrowser(iniFile, "TestBrowser/1.5")
+	require.NoError(t, err)
+
+	csvFile, err := LoadCSVReader(strings.NewReader(TEST_SMALL_CSV), 10)
+	require.NoError(t, err)
+	assert.Equal(t, "1", GetFileVersion(csvFile))
+	assert.Equal(t, GetFileStats(iniFile), GetFileStats(csvFile))
+
+	browser, err := SearchBrowser(csvFile, "TestBrowser/1.5")
+	require.NoError(t, err)
+	assert.Equal(t, expected.Browser, browser.Browser)
+	assert.Equal(t, expected.Version, browser.Version)
+	assert.Equal(t, "Test Browser", browser.Parent)
+
+	jsonFile, err := LoadJSONReader(strings.NewReader(TEST_SMALL_JSON), 10)
+	require.NoError(t, err)
+	assert.Equal(t, "1", GetFileVersion(jsonFile))
+	assert.Equal(t, GetFileStats(iniFile), GetFileStats(jsonFile))
+
+	browser, err = SearchBrowser(jsonFile, "TestBrowser/1.5")
+	require.NoError(t, err)
+	assert.Equal(t, expected.Browser, browser.Browser)
+	assert.Equal(t, expected.Version, browser.Version)
+	assert.True(t, browser.HasIsMobileDevice)
+
+	jsonFile, err = LoadJSONReader(strings.NewReader(TEST_BROWSCAP_JSON), 10)
+	require.NoError(t, err)
+	assert.Equal(t, "6000045", GetFileVersion(jsonFile))
+	assert.Equal(t, "FULL", GetFileMetadata(jsonFile).Type)
+	assert.Equal(t, 1, GetFileStats(jsonFile).Patterns)
+
+	browser, err = SearchBrowser(jsonFile, "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/94.0.4606.81 Safari/537.36")
+	require.NoError(t, err)
+	assert.Equal(t, "Chrome", browser.Browser)
+	assert.Equal(t, "94.0", browser.Version)
+	assert.Equal(t, "Win10", browser.Platform)
+	assert.Equal(t, "Desktop", browser.DeviceType)
+	assert.True(t, browser.HasIsMobileDevice)
+	assert.False(t, browser.IsMobileDevice)
+
+	_, err = LoadJSONReader(strings.NewReader(`{"Test": {"Parent": "Unknown"}}`), 10)
+	var parseErr *ParseError
+	require.True(t, errors.As(err, &parseErr))
+	assert.Equal(t, ParseErrorUnknownParent, parseErr.Kind)
+	assert.Equal(t, 1, parseErr.Line)
+
+	_, err = LoadCSVReader(strings.NewReader(strings.Replace(TEST_SMALL_CSV, `"Test Browser","2"`, `"DefaultProperties","2"`, 1)), 10)
+	require.True(t, errors.As(err, &parseErr))
+	assert.Equal(t, ParseErrorDuplicateSection, parseErr.Kind)
+	assert.Equal(t, 5, parseErr.Line)
+}
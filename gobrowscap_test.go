@@ -19,6 +19,24 @@ const (
 	TEST_MOBILE_FIREFOX = "Mozilla/5.0 (Android 4.1.1; Mobile; rv:55.0) Gecko/55.0 Firefox/55.0"
 )
 
+const TEST_SMALL_INI = `
+[GJK_Browscap_Version]
+Version=1
+
+[DefaultProperties]
+Comment="DefaultProperties"
+Browser="DefaultProperties"
+
+[Test Browser]
+Parent="DefaultProperties"
+Comment="Test Browser"
+Browser="Test Browser"
+
+[TestBrowser/1.*]
+Parent="Test Browser"
+Version="1.0"
+`
+
 var FILE *IniFile
 
 func TestMain(m *testing.M) {
@@ -0,0 +1,304 @@
+package gobrowscap
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// BrandVersion is an entry of the Sec-CH-UA and Sec-CH-UA-Full-Version-List headers.
+type BrandVersion struct {
+	Brand   string
+	Version string
+}
+
+// ClientHints holds the User-Agent Client Hints sent by Chromium based browsers.
+type ClientHints struct {
+	Brands          []BrandVersion /* Sec-CH-UA, with the major versions only */
+	FullVersionList []BrandVersion /* Sec-CH-UA-Full-Version-List */
+	Platform        string         /* Sec-CH-UA-Platform */
+	PlatformVersion string         /* Sec-CH-UA-Platform-Version */
+	Mobile          bool           /* Sec-CH-UA-Mobile */
+	HasMobile       bool
+	Model           string /* Sec-CH-UA-Model */
+}
+
+// the names of the Browser fields set by ApplyClientHints, as listed in Browser.HintedFields
+const (
+	HintedBrowser         = "Browser"
+	HintedVersion         = "Version"
+	HintedPlatform        = "Platform"
+	HintedPlatformVersion = "PlatformVersion"
+	HintedIsMobileDevice  = "IsMobileDevice"
+	HintedDeviceName      = "DeviceName"
+)
+
+/* the brands are mapped to the browscap names, the rest is used as is */
+var hintBrandNames = map[string]string{
+	"Google Chrome":  "Chrome",
+	"Microsoft Edge": "Edge",
+	"Opera":          "Opera",
+	"Chromium":       "Chromium",
+	"Brave":          "Brave",
+	"YaBrowser":      "Yandex Browser",
+}
+
+var hintPlatformNames = map[string]string{
+	"macOS":       "macOS",
+	"Android":     "Android",
+	"iOS":         "iOS",
+	"Linux":       "Linux",
+	"Chrome OS":   "ChromeOS",
+	"Chromium OS": "ChromeOS",
+}
+
+// ParseClientHints extracts the Client Hints from the request headers,
+// it returns nil if there are none.
+func ParseClientHints(header http.Header) *ClientHints {
+	hints := new(ClientHints)
+	found := false
+
+	if value := header.Get("Sec-CH-UA"); value != "" {
+		hints.Brands = parseBrandList(value)
+		found = true
+	}
+	if value := header.Get("Sec-CH-UA-Full-Version-List"); value != "" {
+		hints.FullVersionList = parseBrandList(value)
+		found = true
+	}
+	if value := header.Get("Sec-CH-UA-Platform"); value != "" {
+		hints.Platform = parseHintString(value)
+		found = true
+	}
+	if value := header.Get("Sec-CH-UA-Platform-Version"); value != "" {
+		hints.PlatformVersion = parseHintString(value)
+		found = true
+	}
+	if value := strings.TrimSpace(header.Get("Sec-CH-UA-Mobile")); value == "?0" || value == "?1" {
+		hints.Mobile = value == "?1"
+		hints.HasMobile = true
+		found = true
+	}
+	if value := header.Get("Sec-CH-UA-Model"); value != "" {
+		hints.Model = parseHintString(value)
+		found = true
+	}
+
+	if !found {
+		return nil
+	}
+	return hints
+}
+
+/* reads a structured header string, the value is returned as is if it's not quoted */
+func parseHintString(value string) string {
+	value = strings.TrimSpace(value)
+	if len(value) < 2 || value[0] != '"' {
+		return value
+	}
+
+	str, _ := readQuotedString(value)
+	return str
+}
+
+/* returns the unquoted string and the rest of value after the closing quote */
+func readQuotedString(value string) (string, string) {
+	var builder strings.Builder
+	for i := 1; i < len(value); i++ {
+		switch value[i] {
+		case '\\':
+			if i+1 < len(value) {
+				i++
+				builder.WriteByte(value[i])
+			}
+		case '"':
+			return builder.String(), value[i+1:]
+		default:
+			builder.WriteByte(value[i])
+		}
+	}
+	return builder.String(), ""
+}
+
+/* parses a structured header list like `"Chromium";v="118", "Google Chrome";v="118"` */
+func parseBrandList(value string) []BrandVersion {
+	brands := make([]BrandVersion, 0)
+	for {
+		value = strings.TrimLeft(value, " \t,")
+		if value == "" || value[0] != '"' {
+			return brands
+		}
+
+		var brand BrandVersion
+		brand.Brand, value = readQuotedString(value)
+
+		/* parameters */
+		for strings.HasPrefix(value, ";") {
+			value = strings.TrimLeft(value[1:], " \t")
+			end := strings.IndexAny(value, "=;,")
+			if end < 0 || value[end] != '=' {
+				break
+			}
+			key := value[:end]
+			value = value[end+1:]
+
+			var paramValue string
+			if strings.HasPrefix(value, `"`) {
+				paramValue, value = readQuotedString(value)
+			} else {
+				end := strings.IndexAny(value, ";,")
+				if end < 0 {
+					end = len(value)
+				}
+				paramValue, value = value[:end], value[end:]
+			}
+			if key == "v" {
+				brand.Version = paramValue
+			}
+		}
+
+		brands = append(brands, brand)
+		if comma := strings.IndexByte(value, ','); comma >= 0 {
+			value = value[comma+1:]
+		} else {
+			return brands
+		}
+	}
+}
+
+/* GREASE brands like "Not=A?Brand" are sent to keep the parsers tolerant of unknown values */
+func isGreaseBrand(brand string) bool {
+	return strings.Contains(brand, "Not") && strings.Contains(brand, "Brand")
+}
+
+/* the most specific brand, Chromium is used only if there is nothing else */
+func (hints *ClientHints) brand() (BrandVersion, bool) {
+	brands := hints.FullVersionList
+	if len(brands) == 0 {
+		brands = hints.Brands
+	}
+
+	var chromium *BrandVersion
+	for i, brand := range brands {
+		switch {
+		case isGreaseBrand(brand.Brand):
+		case brand.Brand == "Chromium":
+			chromium = &brands[i]
+		default:
+			return brand, true
+		}
+	}
+	if chromium != nil {
+		return *chromium, true
+	}
+	return BrandVersion{}, false
+}
+
+/* "120.0.6099.71" -> "120.0", "120", "0" */
+func splitHintVersion(version string) (string, string, string) {
+	parts := strings.SplitN(version, ".", 3)
+	if len(parts) == 1 {
+		return parts[0] + ".0", parts[0], "0"
+	}
+	return parts[0] + "." + parts[1], parts[0], parts[1]
+}
+
+/* the Windows platform version reported in the hints is not the marketing one */
+func windowsPlatform(platformVersion string) (string, string, bool) {
+	major, err := strconv.Atoi(strings.SplitN(platformVersion, ".", 2)[0])
+	switch {
+	case err != nil || major < 1:
+		/* 0 means Windows 7, 8 or 8.1, which the User-Agent tells apart better */
+		return "", "", false
+	case major >= 13:
+		return "Win11", "11.0", true
+	}
+	return "Win10", "10.0", true
+}
+
+// ApplyClientHints returns a copy of browser with the fields known from hints replaced
+// by the hinted values and listed in HintedFields. browser is returned as is if hints is nil.
+func ApplyClientHints(browser *Browser, hints *ClientHints) *Browser {
+	if browser == nil || hints == nil {
+		return browser
+	}
+
+	browser = copyBrowser(browser)
+	if browser.Properties == nil {
+		browser.Properties = make(map[string]string)
+	}
+	hinted := func(field string) {
+		browser.HintedFields = append(browser.HintedFields, field)
+	}
+
+	if brand, ok := hints.brand(); ok {
+		name, ok := hintBrandNames[brand.Brand]
+		if !ok {
+			name = brand.Brand
+		}
+		browser.Browser = name
+		browser.Properties["Browser"] = name
+		hinted(HintedBrowser)
+
+		if brand.Version != "" {
+			browser.Version, browser.MajorVersion, browser.MinorVersion = splitHintVersion(brand.Version)
+			browser.Properties["Version"] = browser.Version
+			browser.Properties["MajorVer"] = browser.MajorVersion
+			browser.Properties["MinorVer"] = browser.MinorVersion
+			hinted(HintedVersion)
+		}
+	}
+
+	platform, platformVersion := "", ""
+	if hints.Platform == "Windows" {
+		if name, version, ok := windowsPlatform(hints.PlatformVersion); ok {
+			platform, platformVersion = name, version
+		}
+	} else if name, ok := hintPlatformNames[hints.Platform]; ok {
+		platform = name
+		if hints.PlatformVersion != "" {
+			platformVersion, _, _ = splitHintVersion(hints.PlatformVersion)
+		}
+	}
+	if platform != "" {
+		browser.Platform = platform
+		browser.Properties["Platform"] = platform
+		hinted(HintedPlatform)
+	}
+	if platformVersion != "" {
+		browser.PlatformVersion = platformVersion
+		browser.Properties["Platform_Version"] = platformVersion
+		hinted(HintedPlatformVersion)
+	}
+
+	if hints.HasMobile {
+		browser.IsMobileDevice = hints.Mobile
+		browser.HasIsMobileDevice = true
+		browser.Properties["isMobileDevice"] = strconv.FormatBool(hints.Mobile)
+		hinted(HintedIsMobileDevice)
+	}
+
+	if hints.Model != "" {
+		browser.DeviceName = hints.Model
+		browser.Properties["Device_Name"] = hints.Model
+		hinted(HintedDeviceName)
+	}
+	return browser
+}
+
+// SearchBrowserWithHints is SearchBrowser refining the result with the Client Hints,
+// since Chromium based browsers report a frozen platform and a reduced version in the User-Agent.
+func SearchBrowserWithHints(iniFile *IniFile, userAgent string, hints *ClientHints) (*Browser, error) {
+	browser, err := SearchBrowserContext(context.Background(), iniFile, userAgent)
+	if err != nil {
+		return nil, err
+	}
+	return ApplyClientHints(browser, hints), nil
+}
+
+// SearchBrowserHeader is SearchBrowserWithHints taking the User-Agent and the Client Hints
+// from the request headers.
+func SearchBrowserHeader(iniFile *IniFile, header http.Header) (*Browser, error) {
+	return SearchBrowserWithHints(iniFile, header.Get("User-Agent"), ParseClientHints(header))
+}
@@ -0,0 +1,62 @@
+package gobrowscap
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientHints(t *testing.T) {
+	header := http.Header{}
+	header.Set("User-Agent", "TestBrowser/1.5")
+	header.Set("Sec-CH-UA", `"Not_A Brand";v="8", "Chromium";v="120", "Google Chrome";v="120"`)
+	header.Set("Sec-CH-UA-Full-Version-List", `"Not_A Brand";v="8.0.0.0", "Chromium";v="120.0.6099.71", "Google Chrome";v="120.0.6099.71"`)
+	header.Set("Sec-CH-UA-Platform", `"Windows"`)
+	header.Set("Sec-CH-UA-Platform-Version", `"15.0.0"`)
+	header.Set("Sec-CH-UA-Mobile", "?0")
+	header.Set("Sec-CH-UA-Model", `""`)
+
+	hints := ParseClientHints(header)
+	require.NotNil(t, hints)
+	assert.Equal(t, []BrandVersion{{"Not_A Brand", "8"}, {"Chromium", "120"}, {"Google Chrome", "120"}}, hints.Brands)
+	assert.Equal(t, "Windows", hints.Platform)
+	assert.Equal(t, "15.0.0", hints.PlatformVersion)
+	assert.True(t, hints.HasMobile)
+	assert.False(t, hints.Mobile)
+	assert.Empty(t, hints.Model)
+
+	assert.Nil(t, ParseClientHints(http.Header{"User-Agent": {"TestBrowser/1.5"}}))
+
+	iniFile, err := LoadIniReader(strings.NewReader(TEST_SMALL_INI), 10)
+	require.NoError(t, err)
+
+	browser, err := SearchBrowserHeader(iniFile, header)
+	require.NoError(t, err)
+	assert.Equal(t, "Chrome", browser.Browser)
+	assert.Equal(t, "120.0", browser.Version)
+	assert.Equal(t, "120", browser.MajorVersion)
+	assert.Equal(t, "Win11", browser.Platform)
+	assert.Equal(t, "11.0", browser.PlatformVersion)
+	assert.True(t, browser.HasIsMobileDevice)
+	assert.Equal(t, "Win11", browser.Properties["Platform"])
+	assert.Equal(t, []string{HintedBrowser, HintedVersion, HintedPlatform, HintedPlatformVersion, HintedIsMobileDevice}, browser.HintedFields)
+
+	header.Set("Sec-CH-UA-Platform-Version", `"10.0.0"`)
+	browser, err = SearchBrowserHeader(iniFile, header)
+	require.NoError(t, err)
+	assert.Equal(t, "Win10", browser.Platform)
+
+	/* pre-Windows 10 versions are left to the User-Agent */
+	header.Set("Sec-CH-UA-Platform-Version", `"0.3.0"`)
+	browser, err = SearchBrowserHeader(iniFile, header)
+	require.NoError(t, err)
+	assert.Empty(t, browser.Platform)
+
+	browser, err = SearchBrowserWithHints(iniFile, "TestBrowser/1.5", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "Test Browser", browser.Browser)
+	assert.Nil(t, browser.HintedFields)
+}
@@ -0,0 +1,231 @@
+package gobrowscap
+
+import (
+	"sort"
+)
+
+// The index is built from the words of the patterns: runs of letters in the literal parts
+// of a pattern which are delimited by a literal non-letter, a digit or the pattern boundary
+// on both sides. Any user agent matching the pattern contains each of them as a whole word,
+// so only the patterns whose words are all present in the user agent have to be matched.
+type tokenIndex struct {
+	tokens map[string][]int /* the rarest word of a pattern => indexes of the patterns */
+	always []int            /* patterns without words */
+	words  [][]string       /* all the words of each pattern */
+}
+
+/* the patterns matching in a batch, ordered the same way as the patterns */
+type candidateBatch struct {
+	batch    *Batch
+	patterns []int
+}
+
+func isLetter(c byte) bool {
+	return c >= 'a' && c <= 'z'
+}
+
+/* extracts the delimited words from a quoted lowercase pattern produced by processIniSections */
+func patternWords(patternStr string) []string {
+	words := make([]string, 0)
+
+	inWord := false
+	var word []byte
+	bounded := true /* the start of the pattern is a boundary */
+	for i := 0; i < len(patternStr); i++ {
+		c := patternStr[i]
+		literal := true
+		switch {
+		case c == '\\' && i+1 < len(patternStr):
+			i++
+			c = patternStr[i]
+		case c == '.':
+			/* "?" or "*" wildcard, ".*" is consumed as a whole */
+			if i+1 < len(patternStr) && patternStr[i+1] == '*' {
+				i++
+			}
+			literal = false
+		case c == '(' && i+3 < len(patternStr) && patternStr[i:i+4] == `(\d)`:
+			/* a digit is a non-letter */
+			i += 3
+			c = '0'
+		}
+
+		if literal && isLetter(c) {
+			if !inWord {
+				inWord = true
+				word = word[:0]
+			}
+			word = append(word, c)
+			continue
+		}
+
+		if inWord && bounded && literal {
+			words = append(words, string(word))
+		}
+		inWord = false
+		bounded = literal
+	}
+
+	if inWord && bounded {
+		/* the end of the pattern is a boundary as well */
+		words = append(words, string(word))
+	}
+	return words
+}
+
+/* returns the set of the lowercase letter runs of the user agent */
+func userAgentWords(userAgent string) map[string]struct{} {
+	words := make(map[string]struct{})
+
+	word := make([]byte, 0, 32)
+	for i := 0; i <= len(userAgent); i++ {
+		if i < len(userAgent) {
+			c := userAgent[i]
+			if c >= 'A' && c <= 'Z' {
+				c += 'a' - 'A'
+			}
+			if isLetter(c) {
+				word = append(word, c)
+				continue
+			}
+		}
+
+		if len(word) > 0 {
+			words[string(word)] = struct{}{}
+			word = word[:0]
+		}
+	}
+	return words
+}
+
+func newTokenIndex(patterns []*Pattern) *tokenIndex {
+	index := new(tokenIndex)
+	index.tokens = make(map[string][]int)
+	index.always = make([]int, 0)
+	index.words = make([][]string, len(patterns))
+
+	interned := make(map[string]string)
+	counts := make(map[string]int)
+	for i, pattern := range patterns {
+		words := patternWords(pattern.patternStr)
+		for j, word := range words {
+			if internedWord, ok := interned[word]; ok {
+				words[j] = internedWord
+			} else {
+				interned[word] = word
+			}
+			counts[word]++
+		}
+		index.words[i] = words
+	}
+
+	for i, words := range index.words {
+		if len(words) == 0 {
+			index.always = append(index.always, i)
+			continue
+		}
+
+		rarest := words[0]
+		for _, word := range words[1:] {
+			if counts[word] < counts[rarest] {
+				rarest = word
+			}
+		}
+		index.tokens[rarest] = append(index.tokens[rarest], i)
+	}
+	return index
+}
+
+/*
+copy-on-write, returns the index with the pattern inserted at position and the indexes of the
+following patterns shifted. The pattern is listed under its word with the fewest patterns.
+*/
+func (index *tokenIndex) insert(position int, pattern *Pattern) *tokenIndex {
+	shift := func(indexes []int, inserted bool) []int {
+		shifted := make([]int, 0, len(indexes)+1)
+		for _, patternIndex := range indexes {
+			if patternIndex >= position {
+				if inserted {
+					shifted = append(shifted, position)
+					inserted = false
+				}
+				patternIndex++
+			}
+			shifted = append(shifted, patternIndex)
+		}
+		if inserted {
+			shifted = append(shifted, position)
+		}
+		return shifted
+	}
+
+	words := patternWords(pattern.patternStr)
+	rarest := ""
+	for _, word := range words {
+		if rarest == "" || len(index.tokens[word]) < len(index.tokens[rarest]) {
+			rarest = word
+		}
+	}
+
+	result := new(tokenIndex)
+	result.tokens = make(map[string][]int, len(index.tokens)+1)
+	for word, indexes := range index.tokens {
+		result.tokens[word] = shift(indexes, false)
+	}
+	if rarest != "" {
+		result.tokens[rarest] = shift(index.tokens[rarest], true)
+	}
+	result.always = shift(index.always, rarest == "")
+
+	result.words = make([][]string, 0, len(index.words)+1)
+	result.words = append(result.words, index.words[:position]...)
+	result.words = append(result.words, words)
+	result.words = append(result.words, index.words[position:]...)
+	return result
+}
+
+/* returns the sorted indexes of the patterns which may match the user agent */
+func (index *tokenIndex) candidates(userAgent string) []int {
+	uaWords := userAgentWords(userAgent)
+
+	candidates := make([]int, len(index.always))
+	copy(candidates, index.always)
+
+	for word := range uaWords {
+		for _, patternIndex := range index.tokens[word] {
+			found := true
+			for _, patternWord := range index.words[patternIndex] {
+				if _, ok := uaWords[patternWord]; !ok {
+					found = false
+					break
+				}
+			}
+			if found {
+				candidates = append(candidates, patternIndex)
+			}
+		}
+	}
+
+	sort.Ints(candidates)
+	return candidates
+}
+
+/* groups the candidate patterns by the batches containing them */
+func candidateBatches(iniFile *IniFile, userAgent string) []candidateBatch {
+	batches := make([]candidateBatch, 0)
+	batchIndex := 0
+	for _, patternIndex := range iniFile.index.candidates(userAgent) {
+		/* the candidates are sorted, so the batch is never before the previous one */
+		if iniFile.batches[batchIndex].end <= patternIndex {
+			rest := iniFile.batches[batchIndex:]
+			batchIndex += sort.Search(len(rest), func(i int) bool { return rest[i].end > patternIndex })
+		}
+		batch := iniFile.batches[batchIndex]
+		if len(batches) == 0 || batches[len(batches)-1].batch != batch {
+			batches = append(batches, candidateBatch{batch: batch})
+		}
+		last := &batches[len(batches)-1]
+		last.patterns = append(last.patterns, patternIndex)
+	}
+	return batches
+}
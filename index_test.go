@@ -0,0 +1,131 @@
+package gobrowscap
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPatternWords(t *testing.T) {
+	assert.Equal(t, []string{"mozilla", "compatible", "googlebot"}, patternWords(`mozilla/(\d)\.0 \(compatible; googlebot/2\.1.*`))
+	assert.Equal(t, []string{"abc"}, patternWords(`abc(\d)def.ghi.*jkl`))
+	assert.Equal(t, []string{}, patternWords(`.*`))
+}
+
+func TestTokenIndex(t *testing.T) {
+	data, err := ioutil.ReadFile("test-data/user_agents_sample.txt")
+	require.NoError(t, err)
+
+	uas := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(uas) > 1000 {
+		uas = uas[:1000]
+	}
+
+	/* the index must not change the results of matching all the patterns one by one */
+	for _, ua := range uas {
+		var expected *Browser
+		for _, pattern := range FILE.patterns {
+			if key := matchPattern(pattern, ua); key >= 0 {
+				expected = resolveBrowser(FILE, pattern, key)
+				break
+			}
+		}
+
+		browser, err := SearchBrowser(FILE, ua)
+		require.NoError(t, err)
+		assert.Equal(t, expected, browser, ua)
+	}
+}
+
+/* the words next to the wildcards must not be required, the wildcards may glue letters to them */
+const TEST_WILDCARDS_INI = `
+[GJK_Browscap_Version]
+Version=1
+
+[DefaultProperties]
+Comment="DefaultProperties"
+Browser="DefaultProperties"
+
+[Foo*Bar/1.?]
+Parent="DefaultProperties"
+Browser="Foo Bar"
+
+[*Crawler*]
+Parent="DefaultProperties"
+Browser="Crawler"
+
+[Mozilla/5.0 (*Probe*)]
+Parent="DefaultProperties"
+Browser="Probe"
+
+[App1x*]
+Parent="DefaultProperties"
+Browser="App"
+
+[?ab Client]
+Parent="DefaultProperties"
+Browser="Ab Client"
+`
+
+/* builds a user agent matching patternStr with the wildcards replaced by anyChars and oneChar */
+func patternUserAgent(patternStr string, anyChars string, oneChar string) string {
+	var userAgent strings.Builder
+	for i := 0; i < len(patternStr); i++ {
+		c := patternStr[i]
+		switch {
+		case c == '\\' && i+1 < len(patternStr):
+			i++
+			userAgent.WriteByte(patternStr[i])
+		case c == '.' && i+1 < len(patternStr) && patternStr[i+1] == '*':
+			i++
+			userAgent.WriteString(anyChars)
+		case c == '.':
+			userAgent.WriteString(oneChar)
+		case strings.HasPrefix(patternStr[i:], `(\d)`):
+			i += 3
+			userAgent.WriteByte('7')
+		default:
+			userAgent.WriteByte(c)
+		}
+	}
+	return userAgent.String()
+}
+
+func TestTokenIndexFalseNegatives(t *testing.T) {
+	iniFile, err := LoadIniReader(strings.NewReader(TEST_WILDCARDS_INI), 10)
+	require.NoError(t, err)
+
+	for userAgent, expected := range map[string]string{
+		"FooBar/1.5":             "Foo Bar",
+		"FoolishBar/1.x":         "Foo Bar",
+		"MyCrawlerBot":           "Crawler",
+		"Crawler":                "Crawler",
+		"Mozilla/5.0 (AProbeX)":  "Probe",
+		"Mozilla/5.0 (Probe)":    "Probe",
+		"App1xyz":                "App",
+		"Zab Client":             "Ab Client",
+		"fooXbar/1.0":            "Foo Bar",
+		"mozilla/5.0 (probes 1)": "Probe",
+	} {
+		browser, err := SearchBrowser(iniFile, userAgent)
+		require.NoError(t, err, userAgent)
+		assert.Equal(t, expected, browser.Browser, userAgent)
+	}
+
+	/* every pattern of the full file is a candidate for the user agents it matches */
+	for _, wildcards := range [][2]string{{"", "a"}, {"xyz", "q"}, {" ", " "}, {"A1b", "Z"}} {
+		matched := 0
+		for i, pattern := range FILE.patterns {
+			userAgent := patternUserAgent(pattern.patternStr, wildcards[0], wildcards[1])
+			if !pattern.regex.MatchString(userAgent) {
+				continue
+			}
+			matched++
+			assert.Contains(t, FILE.index.candidates(userAgent), i, userAgent)
+		}
+		assert.Greater(t, matched, len(FILE.patterns)/2, wildcards)
+	}
+}
@@ -11,14 +11,18 @@ package gobrowscap
 import (
 	"bufio"
 	"bytes"
+	"crypto/sha256"
 	"fmt"
 	"io"
+	"io/fs"
+	"io/ioutil"
 	"os"
 	"regexp"
+	"runtime"
 	"sort"
 	"strings"
-
-	"github.com/glenn-brown/golang-pkg-pcre/src/pkg/pcre"
+	"sync/atomic"
+	"time"
 )
 
 type TmpPattern struct {
@@ -42,15 +46,17 @@ type Pattern struct {
 	length      int
 	shortLength int
 	patternStr  string
-	regex       *pcre.Regexp
+	regex       Regexp
 	intval      int
 	matches     map[string]int
 }
 
 type Batch struct {
-	regex      *pcre.Regexp
+	regex      Regexp
 	patternStr string
 	index      int
+	start      int /* the range of the patterns in the batch */
+	end        int
 }
 
 type IniFile struct {
@@ -59,6 +65,42 @@ type IniFile struct {
 	batches   []*Batch
 	batchSize int
 	version   string
+	released  time.Time
+	fileType  string
+	options   *options
+	checksum  [sha256.Size]byte /* of the source data and the overlays, used to detect stale snapshots */
+
+	source       string    /* the path the file was loaded from, if any */
+	parse        parseFunc /* the format of the source, nil for the snapshots */
+	loadDuration time.Duration
+
+	generation uint64 /* increases with every loaded IniFile */
+	pool       *workerPool
+	closed     uint32 /* the pool is released once */
+	index      *tokenIndex
+}
+
+var iniFileGeneration uint64
+
+func newIniFile(options *options) *IniFile {
+	var pool *workerPool
+	if options.workers > 0 {
+		pool = newWorkerPool(options.workers)
+	}
+	return newIniFileWithPool(options, pool)
+}
+
+func newIniFileWithPool(options *options, pool *workerPool) *IniFile {
+	iniFile := new(IniFile)
+	iniFile.generation = atomic.AddUint64(&iniFileGeneration, 1)
+	iniFile.options = options
+
+	if pool != nil {
+		/* the workers don't reference the IniFile, so it's collected when it's not used anymore */
+		iniFile.pool = pool
+		runtime.SetFinalizer(iniFile, (*IniFile).Close)
+	}
+	return iniFile
 }
 
 var (
@@ -74,10 +116,15 @@ var (
 
 	versionSection = "GJK_Browscap_Version"
 	versionKey     = "Version"
+	releasedKey    = "Released"
+	typeKey        = "Type"
 )
 
 type IniSection struct {
+	name                 string
+	line                 int
 	parent               int
+	parentLine           int
 	parentName           string
 	comment              string
 	browser              string
@@ -99,6 +146,14 @@ type IniSection struct {
 	deviceName           string
 	deviceCodeName       string
 	deviceBrandName      string
+	properties           []sectionProperty
+	resolved             *Browser /* with all the parents merged, if precomputed */
+	overlay              string   /* the path of the overlay the section comes from */
+}
+
+type sectionProperty struct {
+	key   string
+	value string
 }
 
 func regexUnquote(quotedRegex string, matches []string) string {
@@ -144,19 +199,22 @@ func regexUnquote(quotedRegex string, matches []string) string {
 	return resultStr
 }
 
-func parseBoolValue(fieldName string, value string, lineNum int) (bool, error) {
+func parseBoolValue(section *IniSection, key string, value string, lineNum int) (bool, *ParseError) {
 	if value == "true" {
 		return true, nil
 	} else if value == "false" {
 		return false, nil
 	}
-	return false, fmt.Errorf("invalid value for %s: expected true/false, got '%s' on line %d", fieldName, value, lineNum)
+	return false, &ParseError{Line: lineNum, Section: section.name, Key: key, Value: value, Kind: ParseErrorInvalidBool}
 }
 
-func parseSectionValues(section *IniSection, key string, value string, lineNum int) (*IniSection, error) {
+func parseSectionValues(section *IniSection, key string, value string, lineNum int) (*IniSection, *ParseError) {
+	section.properties = append(section.properties, sectionProperty{key: key, value: value})
+
 	switch key {
 	case "Parent":
 		section.parentName = value
+		section.parentLine = lineNum
 	case "Comment":
 		section.comment = value
 	case "Browser":
@@ -174,21 +232,21 @@ func parseSectionValues(section *IniSection, key string, value string, lineNum i
 	case "Platform_Version":
 		section.platformVersion = value
 	case "isMobileDevice":
-		isMobileDevice, err := parseBoolValue(key, value, lineNum)
+		isMobileDevice, err := parseBoolValue(section, key, value, lineNum)
 		if err != nil {
 			return nil, err
 		}
 		section.isMobileDevice = isMobileDevice
 		section.hasIsMobileDevice = true
 	case "isTablet":
-		isTablet, err := parseBoolValue(key, value, lineNum)
+		isTablet, err := parseBoolValue(section, key, value, lineNum)
 		if err != nil {
 			return nil, err
 		}
 		section.isTablet = isTablet
 		section.hasIsTablet = true
 	case "Crawler":
-		crawler, err := parseBoolValue(key, value, lineNum)
+		crawler, err := parseBoolValue(section, key, value, lineNum)
 		if err != nil {
 			return nil, err
 		}
@@ -207,27 +265,141 @@ func parseSectionValues(section *IniSection, key string, value string, lineNum i
 	case "Device_Brand_Name":
 		section.deviceBrandName = value
 	default:
-		/* ignore the others */
+		/* available via properties only */
 	}
 	return section, nil
 }
 
-func parseIniFile(path string) (string, map[int]string, map[int]*IniSection, error) {
-	file, err := os.Open(path)
-	if err != nil {
-		return "", nil, nil, err
+/* the properties of the version section */
+type fileVersion struct {
+	version  string
+	released string
+	fileType string
+}
+
+/* builds the sections of all the supported formats, keeping the order they are added in */
+type sectionBuilder struct {
+	sectionNum  int
+	sectionMap  map[string]int
+	sections    map[int]*IniSection
+	keys        map[string]string /* the same few dozen keys are repeated in every section */
+	version     fileVersion
+	parseErrors *parseErrorCollector
+	overlay     string /* the path of the overlay being parsed */
+}
+
+func newSectionBuilder(options *options) *sectionBuilder {
+	builder := new(sectionBuilder)
+	builder.sectionMap = make(map[string]int)
+	builder.sections = make(map[int]*IniSection)
+	builder.keys = make(map[string]string)
+	builder.parseErrors = &parseErrorCollector{collectAll: options.allParseErrors}
+	return builder
+}
+
+/* returns nil for the version section, the duplicates are returned without being registered */
+func (b *sectionBuilder) addSection(name string, lineNum int) (*IniSection, error) {
+	if name == versionSection {
+		return nil, nil
 	}
-	defer file.Close()
 
-	buf := bufio.NewReader(file)
+	section := new(IniSection)
+	section.name = name
+	section.line = lineNum
+	section.overlay = b.overlay
 
-	sectionName := ""
-	sectionNum := 0
-	version := ""
+	if index, ok := b.sectionMap[name]; ok {
+		if b.overlay != "" && b.sections[index].overlay != b.overlay {
+			/* an overlay replaces the sections of the main file and of the previous overlays */
+			b.sections[index] = section
+			return section, nil
+		}
+		/* the properties of the duplicate are parsed, but the section is not registered */
+		return section, b.addError(&ParseError{Line: lineNum, Section: name, Kind: ParseErrorDuplicateSection})
+	}
 
-	sectionMap := make(map[string]int)
-	sections := make(map[int]*IniSection)
+	b.sectionMap[name] = b.sectionNum
+	b.sections[b.sectionNum] = section
+	b.sectionNum++
+	return section, nil
+}
+
+func (b *sectionBuilder) internKey(keyb []byte) string {
+	key, ok := b.keys[string(keyb)]
+	if !ok {
+		key = string(keyb)
+		b.keys[key] = key
+	}
+	return key
+}
+
+/* a nil section stands for the version section */
+func (b *sectionBuilder) addProperty(section *IniSection, key string, value string, lineNum int) error {
+	if interned, ok := b.keys[key]; ok {
+		key = interned
+	} else {
+		b.keys[key] = key
+	}
+
+	if section == nil {
+		if b.overlay != "" {
+			/* the version of the main file is kept */
+			return nil
+		}
+		switch key {
+		case versionKey:
+			b.version.version = value
+		case releasedKey:
+			b.version.released = value
+		case typeKey:
+			b.version.fileType = value
+		}
+		return nil
+	}
 
+	if _, parseErr := parseSectionValues(section, key, value, lineNum); parseErr != nil {
+		return b.addError(parseErr)
+	}
+	return nil
+}
+
+func (b *sectionBuilder) addError(err *ParseError) error {
+	err.File = b.overlay
+	return b.parseErrors.add(err)
+}
+
+/* resolves the parents once all the sections are known */
+func (b *sectionBuilder) finish() (fileVersion, map[int]string, map[int]*IniSection, error) {
+	var sectionMapInverted = make(map[int]string)
+	for index := 0; index < b.sectionNum; index++ {
+		section := b.sections[index]
+		sectionMapInverted[index] = section.name
+
+		parentName := section.parentName
+		if parentName != "" {
+			parentIndex, ok := b.sectionMap[parentName]
+			if ok {
+				section.parent = parentIndex
+			} else {
+				err := b.parseErrors.add(&ParseError{File: section.overlay, Line: section.parentLine, Section: section.name, Key: "Parent", Value: parentName, Kind: ParseErrorUnknownParent})
+				if err != nil {
+					return fileVersion{}, nil, nil, err
+				}
+			}
+		}
+	}
+
+	if err := b.parseErrors.err(); err != nil {
+		return fileVersion{}, nil, nil, err
+	}
+	return b.version, sectionMapInverted, b.sections, nil
+}
+
+func parseIni(reader io.Reader, builder *sectionBuilder) error {
+	buf := bufio.NewReader(reader)
+
+	sectionName := ""
+	var section *IniSection
 	lineNum := 0
 	isVersionSection := false
 	/* parse INI and create maps of sections and properties */
@@ -237,7 +409,7 @@ func parseIniFile(path string) (string, map[int]string, map[int]*IniSection, err
 			if err == io.EOF {
 				break
 			} else {
-				return "", nil, nil, err
+				return err
 			}
 		}
 		lineNum++
@@ -263,19 +435,24 @@ func parseIniFile(path string) (string, map[int]string, map[int]*IniSection, err
 		// Section line
 		if bytes.HasPrefix(line, sStart) && bytes.HasSuffix(line, sEnd) {
 			sectionName = string(line[1 : len(line)-1])
-			if sectionName == versionSection {
-				isVersionSection = true
-			} else {
-				isVersionSection = false
-				sectionMap[sectionName] = sectionNum
-				sections[sectionNum] = new(IniSection)
-				sectionNum++
+			isVersionSection = sectionName == versionSection
+
+			section, err = builder.addSection(sectionName, lineNum)
+			if err != nil {
+				return err
 			}
 			continue
 		}
 
 		// Key => Value
 		kv := bytes.SplitN(line, sEqual, 2)
+		if len(kv) != 2 || (section == nil && !isVersionSection) {
+			err := builder.addError(&ParseError{Line: lineNum, Section: sectionName, Value: string(line), Kind: ParseErrorSyntax})
+			if err != nil {
+				return err
+			}
+			continue
+		}
 
 		// Parse Key
 		keyb := bytes.TrimSpace(kv[0])
@@ -289,42 +466,12 @@ func parseIniFile(path string) (string, map[int]string, map[int]*IniSection, err
 			valb = bytes.Trim(valb, `'`)
 		}
 
-		key := string(keyb)
-		val := string(valb)
-
-		if isVersionSection {
-			if key == versionKey {
-				version = val
-			}
-			continue
-		}
-
-		section, err := parseSectionValues(sections[sectionNum-1], key, val, lineNum)
-		if err != nil {
-			return "", nil, nil, err
+		if err := builder.addProperty(section, builder.internKey(keyb), string(valb), lineNum); err != nil {
+			return err
 		}
-		sections[sectionNum-1] = section
-	}
-
-	if len(sectionMap) != len(sections) {
-		return "", nil, nil, fmt.Errorf("parse failure: number of len(sectionMap) != len(sectionProperties). duplicate section name?")
 	}
 
-	var sectionMapInverted = make(map[int]string)
-	for section, index := range sectionMap {
-		sectionMapInverted[index] = section
-
-		parentName := sections[index].parentName
-		if parentName != "" {
-			parentIndex, ok := sectionMap[parentName]
-			if ok {
-				sections[index].parent = parentIndex
-			} else {
-				return "", nil, nil, fmt.Errorf("unknown Parent value specified (not present in the section names): '%s'", parentName)
-			}
-		}
-	}
-	return version, sectionMapInverted, sections, nil
+	return nil
 }
 
 func mergeMap(a map[int]string, b map[int]string) map[int]string {
@@ -465,52 +612,41 @@ func deduplicatePatterns(patterns map[string]*TmpPattern) map[string]*Deduplicat
 	return resultMap
 }
 
-func compileAndAddBatchRegex(batchesArr []*Batch, patternStr string, batchIndex int) ([]*Batch, error) {
-	regex, err := pcre.Compile(patternStr, pcre.CASELESS)
+func compileAndAddBatchRegex(matcher Matcher, batchesArr []*Batch, patternStr string, batchIndex int, start int, end int) ([]*Batch, error) {
+	regex, err := matcher.Compile(patternStr)
 	if err != nil {
-		return nil, fmt.Errorf("pcre.Compile(%s): %s", patternStr, err.String())
+		return nil, err
 	}
 	batch := Batch{
-		regex:      &regex,
+		regex:      regex,
 		patternStr: patternStr,
 		index:      batchIndex,
+		start:      start,
+		end:        end,
 	}
 	batchesArr = append(batchesArr, &batch)
 	return batchesArr, nil
 }
 
-func createRegexpBatches(patterns []*Pattern, batchSize int) ([]*Batch, error) {
+func batchRegexString(patterns []*Pattern) string {
+	alternatives := make([]string, 0, len(patterns))
+	for _, pattern := range patterns {
+		alternatives = append(alternatives, "(?:"+strings.ToLower(pattern.patternStr)+")")
+	}
+	return "^(?:" + strings.Join(alternatives, "|") + ")$"
+}
+
+/* every batch is a single anchored alternation of batchSize patterns, the last one may be shorter */
+func createRegexpBatches(matcher Matcher, patterns []*Pattern, batchSize int) ([]*Batch, error) {
 	var err error
-	batchIndex := 0
-	numInBatch := 1
 	batches := make([]*Batch, 0, len(patterns)/batchSize+1)
-	batchStr := "^"
-	for i := 0; i < len(patterns); i++ {
-
-		batchStr = batchStr + "(?:" + strings.ToLower(patterns[i].patternStr) + ")"
-
-		if numInBatch < batchSize {
-			batchStr = batchStr + "|"
-			numInBatch++
-			continue
+	for start := 0; start < len(patterns); start += batchSize {
+		end := start + batchSize
+		if end > len(patterns) {
+			end = len(patterns)
 		}
 
-		batchStr = batchStr + "$"
-
-		batches, err = compileAndAddBatchRegex(batches, batchStr, batchIndex)
-		if err != nil {
-			return nil, err
-		}
-		batchIndex++
-
-		numInBatch = 1
-		batchStr = "(?i)^"
-	}
-
-	if numInBatch < batchSize {
-		batchStr = batchStr + "$"
-
-		batches, err = compileAndAddBatchRegex(batches, batchStr, batchIndex)
+		batches, err = compileAndAddBatchRegex(matcher, batches, batchRegexString(patterns[start:end]), len(batches), start, end)
 		if err != nil {
 			return nil, err
 		}
@@ -519,20 +655,30 @@ func createRegexpBatches(patterns []*Pattern, batchSize int) ([]*Batch, error) {
 	return batches, nil
 }
 
-func processIniSections(sectionMap map[int]string, sections map[int]*IniSection) map[string]*TmpPattern {
+/* converts a browscap wildcard pattern to a regex */
+func sectionPatternString(userAgent string) string {
+	pattern := regexp.QuoteMeta(strings.ToLower(userAgent))
+	pattern = strings.Replace(pattern, `:`, `\:`, -1)   //just to make sure the results are
+	pattern = strings.Replace(pattern, `-`, `\-`, -1)   //ordered the same way as in PHP
+	pattern = strings.Replace(pattern, `\*`, `.*`, -1)  //
+	pattern = strings.Replace(pattern, `\?`, `.`, -1)   //
+	pattern = strings.Replace(pattern, `\x`, `\\x`, -1) //the \\x replacement is a fix for "Der gro\xdfe BilderSauger 2.00u" user agent match" (c)
+	return pattern
+}
+
+/* the overlay sections are processed separately, so that they are never merged with the main ones */
+func processIniSections(sectionMap map[int]string, sections map[int]*IniSection, overlay bool) map[string]*TmpPattern {
 	tmpPatterns := make(map[string]*TmpPattern)
 
 	for i := 0; i < len(sectionMap); i++ {
 		userAgent := sectionMap[i]
 		section := sections[i]
+		if (section.overlay != "") != overlay {
+			continue
+		}
 		/* looks like Comment is only present for very high-level sections, which are used as Parent's for others */
 		if section.comment == "" || strings.Contains(userAgent, "*") || strings.Contains(userAgent, "?") {
-			pattern := regexp.QuoteMeta(strings.ToLower(userAgent))
-			pattern = strings.Replace(pattern, `:`, `\:`, -1)   //just to make sure the results are
-			pattern = strings.Replace(pattern, `-`, `\-`, -1)   //ordered the same way as in PHP
-			pattern = strings.Replace(pattern, `\*`, `.*`, -1)  //
-			pattern = strings.Replace(pattern, `\?`, `.`, -1)   //
-			pattern = strings.Replace(pattern, `\x`, `\\x`, -1) //the \\x replacement is a fix for "Der gro\xdfe BilderSauger 2.00u" user agent match" (c)
+			pattern := sectionPatternString(userAgent)
 
 			regex, _ := regexp.Compile(`\d`)
 			matches := regex.FindAllString(pattern, -1)
@@ -566,79 +712,248 @@ func processIniSections(sectionMap map[int]string, sections map[int]*IniSection)
 	return tmpPatterns
 }
 
-func LoadIniFile(path string, batchSize int) (*IniFile, error) {
-	version, sectionMap, sections, err := parseIniFile(path)
+// LoadIniFile loads and indexes the browscap ini file located at path.
+func LoadIniFile(path string, batchSize int, opts ...Option) (*IniFile, error) {
+	return loadFile(path, batchSize, newOptions(opts), parseIni)
+}
+
+func loadFile(path string, batchSize int, options *options, parse parseFunc) (*IniFile, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	iniFile, err := loadReader(file, batchSize, options, parse)
+	if err != nil {
+		return nil, err
+	}
+	iniFile.source = path
+	return iniFile, nil
+}
+
+// LoadIniFS loads and indexes the browscap ini file with the given name from fsys,
+// e.g. an embed.FS.
+func LoadIniFS(fsys fs.FS, name string, batchSize int, opts ...Option) (*IniFile, error) {
+	file, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	iniFile, err := LoadIniReader(file, batchSize, opts...)
 	if err != nil {
 		return nil, err
 	}
+	iniFile.source = name
+	return iniFile, nil
+}
 
-	tmpPatterns := processIniSections(sectionMap, sections)
+// LoadIniReader loads and indexes browscap ini data read from reader.
+// gzip, bzip2 and single-file zip data is decompressed on the fly.
+func LoadIniReader(reader io.Reader, batchSize int, opts ...Option) (*IniFile, error) {
+	return loadIniReader(reader, batchSize, newOptions(opts))
+}
 
-	patterns := deduplicatePatterns(tmpPatterns)
+func loadIniReader(reader io.Reader, batchSize int, options *options) (*IniFile, error) {
+	return loadReader(reader, batchSize, options, parseIni)
+}
 
-	i := 0
-	readyPatterns := make([]*Pattern, len(patterns))
-	for patternString, patternObj := range patterns {
-		regex, err := pcre.Compile("^"+patternString+"$", pcre.CASELESS)
+/* parses the sections of one of the supported formats */
+type parseFunc func(reader io.Reader, builder *sectionBuilder) error
+
+func loadReader(reader io.Reader, batchSize int, options *options, parse parseFunc) (*IniFile, error) {
+	start := time.Now()
+	hash := sha256.New()
+	source := io.TeeReader(reader, hash)
+
+	reader, closer, err := decompressReader(source)
+	if err != nil {
+		return nil, err
+	}
+	if closer != nil {
+		defer closer.Close()
+	}
+
+	builder := newSectionBuilder(options)
+	if err := parse(reader, builder); err != nil {
+		return nil, err
+	}
+
+	/* make sure the checksum covers the whole source, decompressors may stop before its end */
+	if _, err := io.Copy(ioutil.Discard, source); err != nil {
+		return nil, err
+	}
+
+	checksums := make([][sha256.Size]byte, 1, len(options.overlays)+1)
+	copy(checksums[0][:], hash.Sum(nil))
+	for _, path := range options.overlays {
+		checksum, err := parseOverlay(builder, path)
 		if err != nil {
-			return nil, fmt.Errorf("Failed to compile regexp: %s, err: %s", patternString, err)
+			return nil, err
 		}
+		checksums = append(checksums, checksum)
+	}
 
-		decodedPattern := regexUnquote(patternString, nil)
-		decodedPattern = strings.Replace(decodedPattern, `(\d)`, "0", -1)
+	version, sectionMap, sections, err := builder.finish()
+	if err != nil {
+		return nil, err
+	}
 
-		ready := new(Pattern)
-		ready.priority = 1
-		if decodedPattern == "*" {
-			/* "*" has to be the last one */
-			ready.priority = 2
-		}
+	iniFile, err := buildIniFile(version, sectionMap, sections, batchSize, options)
+	if err != nil {
+		return nil, err
+	}
+	iniFile.checksum = combineChecksums(checksums)
+	iniFile.parse = parse
+	iniFile.loadDuration = time.Since(start)
+	return iniFile, nil
+}
 
-		ready.regex = &regex
-		ready.intval = patternObj.intval
-		ready.position = patternObj.position
-		ready.matches = patternObj.matches
-		ready.length = len(decodedPattern)
+/* returns the checksum of the overlay file */
+func parseOverlay(builder *sectionBuilder, path string) ([sha256.Size]byte, error) {
+	var checksum [sha256.Size]byte
 
-		/* this also affects resulting sort order */
-		shortPattern := strings.Replace(decodedPattern, "*", "", -1)
-		shortPattern = strings.Replace(shortPattern, "?", "", -1)
-		ready.shortLength = len(shortPattern)
-		ready.patternStr = patternString
+	file, err := os.Open(path)
+	if err != nil {
+		return checksum, err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	source := io.TeeReader(file, hash)
+	reader, closer, err := decompressReader(source)
+	if err != nil {
+		return checksum, fmt.Errorf("overlay %s: %w", path, err)
+	}
+	if closer != nil {
+		defer closer.Close()
+	}
 
-		readyPatterns[i] = ready
-		i++
+	builder.overlay = path
+	defer func() { builder.overlay = "" }()
+	if err := parseIni(reader, builder); err != nil {
+		return checksum, err
 	}
 
-	sort.Slice(readyPatterns, func(i, j int) bool {
-		a := readyPatterns[i]
-		b := readyPatterns[j]
-		if a.priority != b.priority {
-			return a.priority < b.priority
-		}
-		if a.length != b.length {
-			return a.length > b.length
-		}
-		if a.shortLength != b.shortLength {
-			return a.shortLength > b.shortLength
+	if _, err := io.Copy(ioutil.Discard, source); err != nil {
+		return checksum, err
+	}
+	copy(checksum[:], hash.Sum(nil))
+	return checksum, nil
+}
+
+/* the checksum of the source is used as is without overlays, so that it matches the one of the file */
+func combineChecksums(checksums [][sha256.Size]byte) [sha256.Size]byte {
+	if len(checksums) == 1 {
+		return checksums[0]
+	}
+
+	hash := sha256.New()
+	for _, checksum := range checksums {
+		hash.Write(checksum[:])
+	}
+	var combined [sha256.Size]byte
+	copy(combined[:], hash.Sum(nil))
+	return combined
+}
+
+func newPattern(matcher Matcher, patternString string, patternObj *DeduplicatedPattern) (*Pattern, error) {
+	regex, err := matcher.Compile("^" + patternString + "$")
+	if err != nil {
+		return nil, fmt.Errorf("Failed to compile regexp: %s, err: %s", patternString, err)
+	}
+
+	decodedPattern := regexUnquote(patternString, nil)
+	decodedPattern = strings.Replace(decodedPattern, `(\d)`, "0", -1)
+
+	ready := new(Pattern)
+	ready.priority = 1
+	if decodedPattern == "*" {
+		/* "*" has to be the last one */
+		ready.priority = 2
+	}
+
+	ready.regex = regex
+	ready.intval = patternObj.intval
+	ready.position = patternObj.position
+	ready.matches = patternObj.matches
+	ready.length = len(decodedPattern)
+
+	/* this also affects resulting sort order */
+	shortPattern := strings.Replace(decodedPattern, "*", "", -1)
+	shortPattern = strings.Replace(shortPattern, "?", "", -1)
+	ready.shortLength = len(shortPattern)
+	ready.patternStr = patternString
+	return ready, nil
+}
+
+/* the search order of the patterns */
+func patternLess(a *Pattern, b *Pattern) bool {
+	if a.priority != b.priority {
+		return a.priority < b.priority
+	}
+	if a.length != b.length {
+		return a.length > b.length
+	}
+	if a.shortLength != b.shortLength {
+		return a.shortLength > b.shortLength
+	}
+	if a.position != b.position {
+		return a.position < b.position
+	}
+	return true
+}
+
+func buildIniFile(version fileVersion, sectionMap map[int]string, sections map[int]*IniSection, batchSize int, options *options) (*IniFile, error) {
+	if batchSize < 1 {
+		return nil, fmt.Errorf("invalid batch size %d, expected a positive number", batchSize)
+	}
+
+	patterns := deduplicatePatterns(processIniSections(sectionMap, sections, false))
+	overlayPatterns := deduplicatePatterns(processIniSections(sectionMap, sections, true))
+
+	readyPatterns := make([]*Pattern, 0, len(patterns)+len(overlayPatterns))
+	for patternString, patternObj := range patterns {
+		ready, err := newPattern(options.matcher, patternString, patternObj)
+		if err != nil {
+			return nil, err
 		}
-		if a.position != b.position {
-			return a.position < b.position
+		readyPatterns = append(readyPatterns, ready)
+	}
+	for patternString, patternObj := range overlayPatterns {
+		ready, err := newPattern(options.matcher, patternString, patternObj)
+		if err != nil {
+			return nil, err
 		}
-		return true
+		/* the overlay patterns take precedence over all the others */
+		ready.priority = 0
+		readyPatterns = append(readyPatterns, ready)
+	}
+
+	sort.Slice(readyPatterns, func(i, j int) bool {
+		return patternLess(readyPatterns[i], readyPatterns[j])
 	})
 
-	batches, err := createRegexpBatches(readyPatterns, batchSize)
+	batches, err := createRegexpBatches(options.matcher, readyPatterns, batchSize)
 	if err != nil {
 		return nil, fmt.Errorf("failed to compile batch regex: %w", err)
 	}
 
-	iniFile := new(IniFile)
+	iniFile := newIniFile(options)
 	iniFile.patterns = readyPatterns
 	iniFile.sections = sections
 	iniFile.batches = batches
+	iniFile.index = newTokenIndex(readyPatterns)
+
+	if options.precomputeBrowsers {
+		precomputeBrowsers(iniFile)
+	}
 	iniFile.batchSize = batchSize
-	iniFile.version = version
+	iniFile.version = version.version
+	iniFile.fileType = version.fileType
+	/* the metadata is informational, so a malformed date is not an error */
+	iniFile.released, _ = time.Parse(time.RFC1123Z, version.released)
 
 	return iniFile, nil
 }
@@ -0,0 +1,214 @@
+package gobrowscap
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseErrors(t *testing.T) {
+	const brokenIni = `
+[Test Browser]
+Browser="Test Browser"
+isTablet="maybe"
+
+[Test Browser]
+Browser="Test Browser"
+
+[TestBrowser/1.*]
+Parent="Missing Browser"
+`
+	_, err := LoadIniReader(strings.NewReader(brokenIni), 10)
+	var parseErr *ParseError
+	require.True(t, errors.As(err, &parseErr))
+	assert.Equal(t, ParseErrorInvalidBool, parseErr.Kind)
+	assert.Equal(t, 4, parseErr.Line)
+	assert.Equal(t, "Test Browser", parseErr.Section)
+	assert.Equal(t, "isTablet", parseErr.Key)
+	assert.Equal(t, "maybe", parseErr.Value)
+
+	_, err = LoadIniReader(strings.NewReader(brokenIni), 10, WithAllParseErrors())
+	var parseErrs ParseErrors
+	require.True(t, errors.As(err, &parseErrs))
+	require.Len(t, parseErrs, 3)
+	assert.Equal(t, ParseErrorInvalidBool, parseErrs[0].Kind)
+	assert.Equal(t, ParseErrorDuplicateSection, parseErrs[1].Kind)
+	assert.Equal(t, 6, parseErrs[1].Line)
+	assert.Equal(t, ParseErrorUnknownParent, parseErrs[2].Kind)
+	assert.Equal(t, 10, parseErrs[2].Line)
+	assert.Equal(t, "Missing Browser", parseErrs[2].Value)
+}
+
+func TestLoadIniFS(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+
+	iniFile, err := LoadIniFS(os.DirFS("test-data"), "full_php_browscap.ini", 10)
+	require.NoError(t, err)
+	assert.Equal(t, GetFileVersion(FILE), GetFileVersion(iniFile))
+
+	browser, err := SearchBrowser(iniFile, TEST_USER_AGENT)
+	require.NoError(t, err)
+	assert.Equal(t, "Chrome", browser.Browser)
+}
+
+func TestOverlays(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gobrowscap")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	overridePath := filepath.Join(dir, "override.ini")
+	require.NoError(t, ioutil.WriteFile(overridePath, []byte(`
+[Test Browser]
+Parent="DefaultProperties"
+Comment="Test Browser"
+Browser="Overridden Browser"
+
+[Monitoring Probe/*]
+Parent="Test Browser"
+Version="2.0"
+`), 0644))
+
+	/* shorter than TestBrowser/1.*, but matched first as an overlay pattern */
+	priorityPath := filepath.Join(dir, "priority.ini")
+	require.NoError(t, ioutil.WriteFile(priorityPath, []byte(`
+[TestBrowser/*]
+Parent="Test Browser"
+Version="9.9"
+`), 0644))
+
+	iniFile, err := LoadIniReader(strings.NewReader(TEST_SMALL_INI), 10, WithOverlays(overridePath))
+	require.NoError(t, err)
+
+	browser, err := SearchBrowser(iniFile, "TestBrowser/1.5")
+	require.NoError(t, err)
+	assert.Equal(t, "Overridden Browser", browser.Browser)
+	assert.Equal(t, "1.0", browser.Version)
+	assert.Empty(t, browser.Overlay)
+
+	browser, err = SearchBrowser(iniFile, "Monitoring Probe/1.0")
+	require.NoError(t, err)
+	assert.Equal(t, "Overridden Browser", browser.Browser)
+	assert.Equal(t, "2.0", browser.Version)
+	assert.Equal(t, overridePath, browser.Overlay)
+
+	iniFile, err = LoadIniReader(strings.NewReader(TEST_SMALL_INI), 10, WithOverlays(overridePath, priorityPath))
+	require.NoError(t, err)
+
+	browser, err = SearchBrowser(iniFile, "TestBrowser/1.5")
+	require.NoError(t, err)
+	assert.Equal(t, "9.9", browser.Version)
+	assert.Equal(t, priorityPath, browser.Overlay)
+
+	snapshotPath := filepath.Join(dir, "browscap.snapshot")
+	require.NoError(t, SaveSnapshot(iniFile, snapshotPath))
+	restored, err := LoadSnapshot(snapshotPath)
+	require.NoError(t, err)
+	restoredBrowser, err := SearchBrowser(restored, "TestBrowser/1.5")
+	require.NoError(t, err)
+	assert.Equal(t, browser, restoredBrowser)
+
+	brokenPath := filepath.Join(dir, "broken.ini")
+	require.NoError(t, ioutil.WriteFile(brokenPath, []byte("[Broken/*]\nParent=\"Unknown\"\n"), 0644))
+	_, err = LoadIniReader(strings.NewReader(TEST_SMALL_INI), 10, WithOverlays(brokenPath))
+	var parseErr *ParseError
+	require.True(t, errors.As(err, &parseErr))
+	assert.Equal(t, brokenPath, parseErr.File)
+	assert.Equal(t, 2, parseErr.Line)
+
+	/* the overlays are covered by the snapshot checksum and watched along with the file */
+	iniPath := filepath.Join(dir, "browscap.ini")
+	require.NoError(t, ioutil.WriteFile(iniPath, []byte(TEST_SMALL_INI), 0644))
+	iniFile, err = LoadIniFile(iniPath, 10, WithOverlays(priorityPath))
+	require.NoError(t, err)
+	require.NoError(t, SaveSnapshot(iniFile, snapshotPath))
+
+	stale, err := SnapshotIsStale(snapshotPath, iniPath, priorityPath)
+	require.NoError(t, err)
+	assert.False(t, stale)
+	stale, err = SnapshotIsStale(snapshotPath, iniPath)
+	require.NoError(t, err)
+	assert.True(t, stale)
+
+	detector := NewDetector(iniFile)
+	watcher, err := WatchFile(detector, iniPath, WatchDebounce(10*time.Millisecond), WatchPolling(10*time.Millisecond))
+	require.NoError(t, err)
+	defer watcher.Close()
+
+	require.NoError(t, ioutil.WriteFile(priorityPath, []byte("[TestBrowser/*]\nParent=\"Test Browser\"\nVersion=\"8.8\"\n"), 0644))
+	select {
+	case event := <-watcher.Events():
+		assert.Equal(t, WatchReloaded, event.Type)
+		assert.Equal(t, iniPath, event.Path)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no reload event")
+	}
+	browser, err = detector.Search("TestBrowser/1.5")
+	require.NoError(t, err)
+	assert.Equal(t, "8.8", browser.Version)
+
+	stale, err = SnapshotIsStale(snapshotPath, iniPath, priorityPath)
+	require.NoError(t, err)
+	assert.True(t, stale)
+}
+
+func TestOverlayReplacement(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gobrowscap")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	/* replaces a pattern section with another parent and the root inherited by all the sections */
+	patchPath := filepath.Join(dir, "patch.ini")
+	require.NoError(t, ioutil.WriteFile(patchPath, []byte(`
+[TestBrowser/1.*]
+Parent="DefaultProperties"
+Browser="Patched Browser"
+Version="1.1"
+
+[DefaultProperties]
+Comment="DefaultProperties"
+Browser="DefaultProperties"
+Platform="Overlay Platform"
+`), 0644))
+
+	/* replaces the same pattern section again */
+	hotfixPath := filepath.Join(dir, "hotfix.ini")
+	require.NoError(t, ioutil.WriteFile(hotfixPath, []byte(`
+[TestBrowser/1.*]
+Parent="Test Browser"
+Version="1.2"
+`), 0644))
+
+	for _, test := range []struct {
+		overlays []string
+		browser  string
+		version  string
+		overlay  string
+	}{
+		{[]string{patchPath}, "Patched Browser", "1.1", patchPath},
+		{[]string{patchPath, hotfixPath}, "Test Browser", "1.2", hotfixPath},
+		{[]string{hotfixPath, patchPath}, "Patched Browser", "1.1", patchPath},
+	} {
+		iniFile, err := LoadIniReader(strings.NewReader(TEST_SMALL_INI), 10, WithOverlays(test.overlays...))
+		require.NoError(t, err, test.overlays)
+
+		/* the replaced section keeps a single pattern */
+		require.Len(t, iniFile.patterns, 1, test.overlays)
+
+		browser, err := SearchBrowser(iniFile, "TestBrowser/1.5")
+		require.NoError(t, err, test.overlays)
+		assert.Equal(t, test.browser, browser.Browser, test.overlays)
+		assert.Equal(t, test.version, browser.Version, test.overlays)
+		assert.Equal(t, "Overlay Platform", browser.Platform, test.overlays)
+		assert.Equal(t, test.overlay, browser.Overlay, test.overlays)
+		assert.Len(t, iniFile.sections, 3, test.overlays)
+	}
+}
@@ -0,0 +1,73 @@
+package gobrowscap
+
+import (
+	"regexp"
+	"sync"
+)
+
+// Regexp is a compiled browscap pattern or a batch of patterns.
+type Regexp interface {
+	// MatchString reports whether s matches the expression.
+	MatchString(s string) bool
+	// FindStringSubmatch returns the text of the match followed by the text of
+	// each capture group, or nil if s does not match.
+	FindStringSubmatch(s string) []string
+}
+
+// Matcher compiles the case-insensitive regular expressions used to match user agents.
+type Matcher interface {
+	Compile(pattern string) (Regexp, error)
+}
+
+// GoMatcher is a pure Go Matcher based on the regexp package, it doesn't require cgo or libpcre.
+var GoMatcher Matcher = goMatcher{}
+
+type goMatcher struct{}
+
+/*
+compiles the expression on its first use. The regexes of the patterns are only used
+after their batch matched, so most of them are never compiled in a restored snapshot.
+The snapshot was written from compiled regexes and its checksum is verified, an expression
+failing to compile anyway never matches.
+*/
+type lazyRegexp struct {
+	once    sync.Once
+	matcher Matcher
+	pattern string
+	regex   Regexp
+}
+
+func newLazyRegexp(matcher Matcher, pattern string) *lazyRegexp {
+	return &lazyRegexp{matcher: matcher, pattern: pattern}
+}
+
+func (r *lazyRegexp) compiled() Regexp {
+	r.once.Do(func() {
+		regex, err := r.matcher.Compile(r.pattern)
+		if err == nil {
+			r.regex = regex
+		}
+	})
+	return r.regex
+}
+
+func (r *lazyRegexp) MatchString(s string) bool {
+	regex := r.compiled()
+	return regex != nil && regex.MatchString(s)
+}
+
+func (r *lazyRegexp) FindStringSubmatch(s string) []string {
+	regex := r.compiled()
+	if regex == nil {
+		return nil
+	}
+	return regex.FindStringSubmatch(s)
+}
+
+func (goMatcher) Compile(pattern string) (Regexp, error) {
+	regex, err := regexp.Compile("(?i)" + pattern)
+	if err != nil {
+		return nil, err
+	}
+	return regex, nil
+}
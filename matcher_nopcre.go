@@ -0,0 +1,5 @@
+//go:build !cgo || nopcre
+
+package gobrowscap
+
+var defaultMatcher = GoMatcher
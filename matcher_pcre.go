@@ -0,0 +1,45 @@
+//go:build cgo && !nopcre
+
+package gobrowscap
+
+import (
+	"fmt"
+
+	"github.com/glenn-brown/golang-pkg-pcre/src/pkg/pcre"
+)
+
+// PCREMatcher is a Matcher based on libpcre, it is the default one when cgo is enabled.
+var PCREMatcher Matcher = pcreMatcher{}
+
+var defaultMatcher = PCREMatcher
+
+type pcreMatcher struct{}
+
+type pcreRegexp struct {
+	regex pcre.Regexp
+}
+
+func (pcreMatcher) Compile(pattern string) (Regexp, error) {
+	regex, err := pcre.Compile(pattern, pcre.CASELESS)
+	if err != nil {
+		return nil, fmt.Errorf("pcre.Compile(%s): %s", pattern, err.String())
+	}
+	return &pcreRegexp{regex: regex}, nil
+}
+
+func (r *pcreRegexp) MatchString(s string) bool {
+	return r.regex.MatcherString(s, 0).Matches()
+}
+
+func (r *pcreRegexp) FindStringSubmatch(s string) []string {
+	matcher := r.regex.MatcherString(s, 0)
+	if !matcher.Matches() {
+		return nil
+	}
+
+	groups := make([]string, matcher.Groups()+1)
+	for i := range groups {
+		groups[i] = matcher.GroupString(i)
+	}
+	return groups
+}
@@ -0,0 +1,58 @@
+package gobrowscap
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGoMatcher(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+
+	iniFile, err := LoadIniFile(TEST_INI_FILE, 10, WithMatcher(GoMatcher))
+	require.NoError(t, err)
+
+	for _, test := range []struct {
+		userAgent string
+		browser   string
+		platform  string
+		version   string
+	}{
+		{TEST_USER_AGENT, "Chrome", "MacOSX", "37.0"},
+		{TEST_IPHONE_AGENT, "Safari", "iOS", "5.0"},
+		{TEST_YANDEX_AGENT, "Yandex Browser", "Win7", "13.12"},
+		{TEST_ANDROID_AGENT, "Chrome", "Android", "39.0"},
+		{TEST_MOBILE_FIREFOX, "Firefox", "Android", "55.0"},
+	} {
+		browser, err := SearchBrowser(iniFile, test.userAgent)
+		require.NoError(t, err, test.userAgent)
+		assert.Equal(t, test.browser, browser.Browser, test.userAgent)
+		assert.Equal(t, test.platform, browser.Platform, test.userAgent)
+		assert.Equal(t, test.version, browser.Version, test.userAgent)
+	}
+
+	/* FILE is loaded with GoMatcher as well when PCRE is not compiled in */
+	if defaultMatcher == GoMatcher {
+		return
+	}
+
+	data, err := ioutil.ReadFile("test-data/user_agents_sample.txt")
+	require.NoError(t, err)
+
+	uas := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(uas) > 1000 {
+		uas = uas[:1000]
+	}
+
+	for _, ua := range uas {
+		expected, expectedErr := SearchBrowser(FILE, ua)
+		browser, err := SearchBrowser(iniFile, ua)
+		assert.Equal(t, expectedErr, err, ua)
+		assert.Equal(t, expected, browser, ua)
+	}
+}
@@ -0,0 +1,87 @@
+package gobrowscap
+
+import (
+	"context"
+	"errors"
+	"net/http"
+)
+
+// Searcher looks up user agents, it's implemented by Detector.
+type Searcher interface {
+	SearchContext(ctx context.Context, userAgent string) (*Browser, error)
+}
+
+type browserContextKey struct{}
+
+// NewContext returns a copy of ctx carrying browser, see FromContext.
+func NewContext(ctx context.Context, browser *Browser) context.Context {
+	return context.WithValue(ctx, browserContextKey{}, browser)
+}
+
+// FromContext returns the browser stored in ctx by Middleware or NewContext.
+func FromContext(ctx context.Context) (*Browser, bool) {
+	browser, ok := ctx.Value(browserContextKey{}).(*Browser)
+	return browser, ok && browser != nil
+}
+
+// MiddlewareOption configures Middleware.
+type MiddlewareOption func(*middleware)
+
+type middleware struct {
+	searcher     Searcher
+	vary         bool
+	errorHandler func(w http.ResponseWriter, r *http.Request, err error)
+}
+
+// MiddlewareVary makes the middleware add User-Agent to the Vary header of the responses,
+// so that caches don't serve the content selected for one browser to another.
+func MiddlewareVary() MiddlewareOption {
+	return func(m *middleware) {
+		m.vary = true
+	}
+}
+
+// MiddlewareErrorHandler sets the handler responding to the requests whose lookup failed with
+// an error other than ErrNotFound, e.g. because the request was canceled. By default such requests
+// are passed to the next handler without a Browser, the same as the requests with unknown user agents.
+func MiddlewareErrorHandler(handler func(w http.ResponseWriter, r *http.Request, err error)) MiddlewareOption {
+	return func(m *middleware) {
+		m.errorHandler = handler
+	}
+}
+
+// Middleware returns a wrapper of http handlers looking up the User-Agent header of every request
+// with searcher and storing the result in the request context, see FromContext. To serve an IniFile
+// with caching use NewDetector(iniFile, WithCache(size, ttl)) as the searcher.
+func Middleware(searcher Searcher, opts ...MiddlewareOption) func(http.Handler) http.Handler {
+	m := new(middleware)
+	m.searcher = searcher
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if m.vary {
+				w.Header().Add("Vary", "User-Agent")
+			}
+
+			userAgent := r.Header.Get("User-Agent")
+			if userAgent == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			browser, err := m.searcher.SearchContext(r.Context(), userAgent)
+			if err != nil && !errors.Is(err, ErrNotFound) && m.errorHandler != nil {
+				m.errorHandler(w, r, err)
+				return
+			}
+
+			if browser != nil {
+				r = r.WithContext(NewContext(r.Context(), browser))
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
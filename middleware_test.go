@@ -0,0 +1,66 @@
+package gobrowscap
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type notFoundSearcher struct{}
+
+func (notFoundSearcher) SearchContext(ctx context.Context, userAgent string) (*Browser, error) {
+	return nil, fmt.Errorf("failed to look up '%s': %w", userAgent, ErrNotFound)
+}
+
+func TestMiddleware(t *testing.T) {
+	iniFile, err := LoadIniReader(strings.NewReader(TEST_SMALL_INI), 10)
+	require.NoError(t, err)
+
+	var browser *Browser
+	var found bool
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		browser, found = FromContext(r.Context())
+	})
+
+	server := Middleware(NewDetector(iniFile, WithCache(10, 0)), MiddlewareVary())(handler)
+
+	request := httptest.NewRequest("GET", "/", nil)
+	request.Header.Set("User-Agent", "TestBrowser/1.5")
+	recorder := httptest.NewRecorder()
+	server.ServeHTTP(recorder, request)
+	require.True(t, found)
+	assert.Equal(t, "Test Browser", browser.Browser)
+	assert.Equal(t, "User-Agent", recorder.Header().Get("Vary"))
+
+	request.Header.Set("User-Agent", "Unknown/1.0")
+	server.ServeHTTP(httptest.NewRecorder(), request)
+	assert.False(t, found)
+
+	/* the lookup of a canceled request fails */
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	request = httptest.NewRequest("GET", "/", nil).WithContext(ctx)
+	request.Header.Set("User-Agent", "TestBrowser/1.5")
+
+	server = Middleware(NewDetector(iniFile), MiddlewareErrorHandler(func(w http.ResponseWriter, r *http.Request, err error) {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+	}))(handler)
+	found = true
+	recorder = httptest.NewRecorder()
+	server.ServeHTTP(recorder, request)
+	assert.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+	assert.True(t, found, "the next handler must not be called")
+
+	/* a user agent not found by a wrapping searcher is not an error */
+	server = Middleware(notFoundSearcher{}, MiddlewareErrorHandler(func(w http.ResponseWriter, r *http.Request, err error) {
+		t.Errorf("unexpected error: %v", err)
+	}))(handler)
+	server.ServeHTTP(httptest.NewRecorder(), request)
+	assert.False(t, found)
+}
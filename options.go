@@ -0,0 +1,81 @@
+package gobrowscap
+
+import (
+	"runtime"
+)
+
+// Option configures how an IniFile is loaded and searched.
+type Option func(*options)
+
+type options struct {
+	defaultBrowser *Browser
+	allParseErrors bool
+	matcher        Matcher
+	workers        int
+
+	precomputeBrowsers bool
+	overlays           []string
+}
+
+func newOptions(opts []Option) *options {
+	o := new(options)
+	o.matcher = defaultMatcher
+	o.workers = runtime.NumCPU()
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// WithDefaultBrowser makes SearchBrowser return a copy of browser instead of ErrNotFound
+// when no pattern matches the user agent.
+func WithDefaultBrowser(browser Browser) Option {
+	return func(o *options) {
+		/* the properties of the caller's browser are not shared */
+		o.defaultBrowser = copyBrowser(&browser)
+	}
+}
+
+// WithAllParseErrors makes the loaders report every problem found in the data as ParseErrors
+// instead of stopping at the first ParseError.
+func WithAllParseErrors() Option {
+	return func(o *options) {
+		o.allParseErrors = true
+	}
+}
+
+// WithMatcher selects the regular expression engine used to match user agents,
+// e.g. GoMatcher for builds without cgo. PCREMatcher is used by default when it's available.
+func WithMatcher(matcher Matcher) Option {
+	return func(o *options) {
+		o.matcher = matcher
+	}
+}
+
+// WithWorkers sets the number of goroutines matching the batch regexes of all the searches in
+// the IniFile, runtime.NumCPU() by default. Zero starts a goroutine per batch on every search instead.
+func WithWorkers(workers int) Option {
+	return func(o *options) {
+		o.workers = workers
+	}
+}
+
+// WithPrecomputedBrowsers makes the loaders merge the properties of all the parents of every
+// section in advance, so that a search only has to copy the resulting Browser without its
+// Properties. This takes considerably more memory. The Properties map of the results is shared
+// by all of them and must not be modified, the other fields can be.
+func WithPrecomputedBrowsers() Option {
+	return func(o *options) {
+		o.precomputeBrowsers = true
+	}
+}
+
+// WithOverlays makes the loaders merge the browscap ini files at paths into the loaded data,
+// in the given order. The sections of the overlays replace the sections of the same name and
+// their patterns are matched before all the others, Browser.Overlay tells which overlay the
+// matched section comes from. The overlays are ignored by LoadSnapshot, snapshots include them.
+func WithOverlays(paths ...string) Option {
+	return func(o *options) {
+		o.overlays = append(o.overlays, paths...)
+	}
+}
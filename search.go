@@ -1,13 +1,15 @@
 package gobrowscap
 
 import (
-	"regexp"
-	"runtime"
+	"context"
+	"errors"
 	"sort"
 	"strings"
-	"sync"
 )
 
+// ErrNotFound is returned by SearchBrowser when no pattern matches the user agent.
+var ErrNotFound = errors.New("no matching browser found")
+
 func mergeProperties(browser *Browser, section *IniSection) *Browser {
 	if browser.Parent == "" {
 		browser.Parent = section.parentName
@@ -83,171 +85,190 @@ func mergeProperties(browser *Browser, section *IniSection) *Browser {
 	if browser.DeviceBrandName == "" {
 		browser.DeviceBrandName = section.deviceBrandName
 	}
+
+	if browser.Properties == nil {
+		browser.Properties = make(map[string]string)
+	}
+	for _, property := range section.properties {
+		if value, ok := browser.Properties[property.key]; !ok || value == "" {
+			browser.Properties[property.key] = property.value
+		}
+	}
 	return browser
 }
 
-var filterRegex = regexp.MustCompile(`^([A-Za-z]+)[^A-Za-z]+([A-Za-z]+)[^A-Za-z]+([A-Za-z]+).*`)
+/* returns the index of the section matched by the pattern or -1 */
+func matchPattern(pattern *Pattern, userAgent string) int {
+	if pattern.matches == nil {
+		if !pattern.regex.MatchString(userAgent) {
+			return -1
+		}
+		return pattern.intval
+	}
 
-const filterSize = 3
+	groups := pattern.regex.FindStringSubmatch(userAgent)
+	if groups == nil {
+		return -1
+	}
 
-func filterCreate(userAgent string) []string {
+	matchString := "@" + strings.Join(groups[1:], "|")
 
-	matches := filterRegex.FindStringSubmatch(strings.ToLower(userAgent))
-	if len(matches) == filterSize+1 /* leftmost part + 3 words */ {
-		words := make([]string, filterSize)
-		for i := 0; i < filterSize; i++ {
-			words[i] = matches[i+1]
-		}
-		return words
+	key, ok := pattern.matches[matchString]
+	if !ok {
+		/* partial match */
+		return -1
 	}
-	return nil
+	return key
 }
 
-func filterBatches(iniFile *IniFile, userAgent string) []int {
-
-	filter := filterCreate(userAgent)
-	if filter != nil {
-		batchesToSearch := make([]int, 0)
-		for i := 0; i < len(iniFile.batches); i++ {
-			wordsFound := 0
-			for j := 0; j < filterSize; j++ {
-				if strings.Contains(iniFile.batches[i].patternStr, filter[j]) {
-					wordsFound++
-				} else {
-					break
-				}
-			}
-			if wordsFound == filterSize {
-				batchesToSearch = append(batchesToSearch, i)
-			}
-		}
-		return batchesToSearch
+func resolveBrowser(iniFile *IniFile, pattern *Pattern, key int) *Browser {
+	section := iniFile.sections[key]
+	if section.resolved != nil {
+		/* the properties of the precomputed browser are shared by all the results */
+		browser := *section.resolved
+		return &browser
 	}
-	return nil
-}
 
-func searchInBatches(iniFile *IniFile, batches []*Batch, userAgent string) (*Browser, error) {
-	/* run search on all cores at once */
-	goroutineBatchesNum := len(batches)/runtime.NumCPU() + 1
+	browser := new(Browser)
+	browser.Pattern = pattern.patternStr
+	browser.Overlay = section.overlay
+	browser = mergeProperties(browser, section)
+	for section.parentName != "" {
+		section = iniFile.sections[section.parent]
+		browser = mergeProperties(browser, section)
+	}
+	return browser
+}
 
-	resultChan := make(chan int)
-	waitFor := runtime.NumCPU()
-	arrIndex := 0
-	for i := 0; i < goroutineBatchesNum; i++ {
-		var wg sync.WaitGroup
+/* every section is matched by a single pattern, so its resolved browser is always the same */
+func precomputeBrowsers(iniFile *IniFile) {
+	for _, pattern := range iniFile.patterns {
+		keys := make([]int, 0, len(pattern.matches)+1)
+		if pattern.matches == nil {
+			keys = append(keys, pattern.intval)
+		}
+		for _, key := range pattern.matches {
+			keys = append(keys, key)
+		}
 
-		foundBatchIndexes := make([]int, 0)
+		for _, key := range keys {
+			section := iniFile.sections[key]
+			section.resolved = resolveBrowser(iniFile, pattern, key)
+		}
+	}
+}
 
-		if i == goroutineBatchesNum-1 {
-			waitFor = len(batches) - runtime.NumCPU()*i
+func searchInBatches(ctx context.Context, iniFile *IniFile, candidates []candidateBatch, userAgent string, sequential bool) (*Browser, error) {
+	/* match as many batches at once as there are workers */
+	roundSize := iniFile.roundSize()
+	if sequential {
+		roundSize = 1
+	}
+	/* a canceled search fails even if there are no candidates to match */
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	for start := 0; start < len(candidates); start += roundSize {
+		end := start + roundSize
+		if end > len(candidates) {
+			end = len(candidates)
 		}
 
-		wg.Add(waitFor + 1)
-
-		for j := 0; j < waitFor; j++ {
-			go func(arrIndex int, userAgent string) {
-				defer func() {
-					if r := recover(); r != nil {
-						resultChan <- -1
-					}
-				}()
-				defer wg.Done()
-				batchMatches := batches[arrIndex].regex.MatcherString(userAgent, 0).Matches()
-				if batchMatches {
-					resultChan <- batches[arrIndex].index
-				} else {
-					resultChan <- -1
-				}
-			}(arrIndex, userAgent)
-			arrIndex++
+		batches := make([]*Batch, 0, end-start)
+		for _, candidate := range candidates[start:end] {
+			batches = append(batches, candidate.batch)
 		}
 
-		go func() {
-			defer wg.Done()
-			for k := 0; k < waitFor; k++ {
-				result := <-resultChan
-				if result != -1 {
-					foundBatchIndexes = append(foundBatchIndexes, result)
-				}
-			}
-		}()
-
-		wg.Wait()
-
-		if len(foundBatchIndexes) > 0 {
-			sort.Ints(foundBatchIndexes)
-
-			for _, batchIndex := range foundBatchIndexes {
-				for i := batchIndex * iniFile.batchSize; i < (batchIndex+1)*iniFile.batchSize && i < len(iniFile.patterns); i++ {
-					pattern := iniFile.patterns[i]
-					matcher := pattern.regex.MatcherString(userAgent, 0)
-					hasMatches := matcher.Matches()
-					if !hasMatches {
-						continue
-					}
-
-					var key int
-					if matcher.Groups() == 0 {
-						key = pattern.intval
-					} else {
-						matchString := "@"
-
-						for m := 1; m < matcher.Groups()+1; m++ {
-							if m == matcher.Groups() {
-								matchString = matchString + matcher.GroupString(m)
-							} else {
-								matchString = matchString + matcher.GroupString(m) + "|"
-							}
-						}
-
-						var ok bool
-						key, ok = pattern.matches[matchString]
-						if !ok {
-							/* partial match, continue search */
-							continue
-						}
-					}
-
-					section := iniFile.sections[key]
-
-					browser := new(Browser)
-					browser.Pattern = pattern.patternStr
-					browser = mergeProperties(browser, section)
-					for section.parentName != "" {
-						section = iniFile.sections[section.parent]
-						browser = mergeProperties(browser, section)
-					}
-
-					return browser, nil
+		foundPositions := matchBatches(iniFile, batches, userAgent, sequential)
+		sort.Ints(foundPositions)
+
+		for _, position := range foundPositions {
+			for _, patternIndex := range candidates[start+position].patterns {
+				pattern := iniFile.patterns[patternIndex]
+
+				key := matchPattern(pattern, userAgent)
+				if key < 0 {
+					/* no match or partial match, continue search */
+					continue
 				}
+
+				return resolveBrowser(iniFile, pattern, key), nil
 			}
 		}
+
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
 	}
 	return nil, nil
 }
 
-func SearchBrowser(iniFile *IniFile, userAgent string) (*Browser, error) {
+type patternMatch struct {
+	patternIndex int
+	key          int
+}
 
-	var filteredBatches []*Batch
-	filteredBatchesIndexes := filterBatches(iniFile, userAgent)
-	if len(filteredBatchesIndexes) == 0 {
-		return searchInBatches(iniFile, iniFile.batches, userAgent)
-	} else {
-		filteredBatches = make([]*Batch, 0, len(filteredBatchesIndexes))
-		for _, index := range filteredBatchesIndexes {
-			filteredBatches = append(filteredBatches, iniFile.batches[index])
-		}
+/* returns every matching pattern in the search order and the positions of the matching candidate batches */
+func matchAll(iniFile *IniFile, candidates []candidateBatch, userAgent string) ([]patternMatch, []int) {
+	batches := make([]*Batch, 0, len(candidates))
+	for _, candidate := range candidates {
+		batches = append(batches, candidate.batch)
+	}
 
-		browser, err := searchInBatches(iniFile, filteredBatches, userAgent)
-		if err != nil {
-			return nil, err
-		}
+	foundPositions := matchBatches(iniFile, batches, userAgent, false)
+	sort.Ints(foundPositions)
 
-		if browser != nil {
-			return browser, nil
+	matches := make([]patternMatch, 0)
+	for _, position := range foundPositions {
+		for _, patternIndex := range candidates[position].patterns {
+			key := matchPattern(iniFile.patterns[patternIndex], userAgent)
+			if key >= 0 {
+				matches = append(matches, patternMatch{patternIndex: patternIndex, key: key})
+			}
 		}
+	}
+	return matches, foundPositions
+}
 
-		/* repeat with the full list */
-		return searchInBatches(iniFile, iniFile.batches, userAgent)
+// SearchBrowser returns the browser matching userAgent or ErrNotFound if there is none.
+func SearchBrowser(iniFile *IniFile, userAgent string) (*Browser, error) {
+	return SearchBrowserContext(context.Background(), iniFile, userAgent)
+}
+
+// SearchBrowserContext is SearchBrowser that stops searching and returns ctx.Err()
+// once ctx is done. The context is checked between the rounds of batch matching.
+func SearchBrowserContext(ctx context.Context, iniFile *IniFile, userAgent string) (*Browser, error) {
+	return search(ctx, iniFile, userAgent, false)
+}
+
+// SearchAllBrowsers returns every pattern matching userAgent resolved to its section and Browser,
+// in the order the patterns are searched in. The first one is what SearchBrowser returns.
+func SearchAllBrowsers(iniFile *IniFile, userAgent string) []PatternMatch {
+	matches, _ := matchAll(iniFile, candidateBatches(iniFile, userAgent), userAgent)
+
+	results := make([]PatternMatch, 0, len(matches))
+	for _, match := range matches {
+		results = append(results, newPatternMatch(iniFile, match))
+	}
+	return results
+}
+
+/* sequential search matches the batches one by one in the calling goroutine */
+func search(ctx context.Context, iniFile *IniFile, userAgent string, sequential bool) (*Browser, error) {
+	browser, err := searchBrowser(ctx, iniFile, userAgent, sequential)
+	if err != nil {
+		return nil, err
 	}
+
+	if browser == nil {
+		if iniFile.options.defaultBrowser == nil {
+			return nil, ErrNotFound
+		}
+		return copyBrowser(iniFile.options.defaultBrowser), nil
+	}
+	return browser, nil
+}
+
+func searchBrowser(ctx context.Context, iniFile *IniFile, userAgent string, sequential bool) (*Browser, error) {
+	return searchInBatches(ctx, iniFile, candidateBatches(iniFile, userAgent), userAgent, sequential)
 }
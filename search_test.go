@@ -0,0 +1,141 @@
+package gobrowscap
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSearchBrowserProperties(t *testing.T) {
+	browser, err := SearchBrowser(FILE, TEST_USER_AGENT)
+	require.NoError(t, err)
+
+	/* the properties without a field are merged from the parents as well */
+	assert.Equal(t, "Chrome", browser.Properties["Browser"])
+	assert.Equal(t, "Blink", browser.Properties["RenderingEngine_Name"])
+}
+
+func TestSearchBrowserNotFound(t *testing.T) {
+	iniFile, err := LoadIniReader(strings.NewReader(TEST_SMALL_INI), 10)
+	require.NoError(t, err)
+
+	browser, err := SearchBrowser(iniFile, "TestBrowser/1.5")
+	require.NoError(t, err)
+	assert.Equal(t, "Test Browser", browser.Browser)
+
+	browser, err = SearchBrowser(iniFile, TEST_USER_AGENT)
+	assert.True(t, errors.Is(err, ErrNotFound))
+	assert.Nil(t, browser)
+
+	defaultBrowser := Browser{Browser: "Default Browser", Properties: map[string]string{"Browser": "Default Browser"}}
+	iniFile, err = LoadIniReader(strings.NewReader(TEST_SMALL_INI), 10, WithDefaultBrowser(defaultBrowser))
+	require.NoError(t, err)
+	defaultBrowser.Properties["Browser"] = "Modified"
+
+	browser, err = SearchBrowser(iniFile, TEST_USER_AGENT)
+	require.NoError(t, err)
+	assert.Equal(t, "Default Browser", browser.Browser)
+	assert.Equal(t, "Default Browser", browser.Properties["Browser"])
+	browser.Properties["Browser"] = "Modified"
+
+	browser, err = SearchBrowser(iniFile, TEST_USER_AGENT)
+	require.NoError(t, err)
+	assert.Equal(t, "Default Browser", browser.Properties["Browser"])
+}
+
+func TestSearchBrowserContext(t *testing.T) {
+	browser, err := SearchBrowserContext(context.Background(), FILE, TEST_USER_AGENT)
+	require.NoError(t, err)
+	assert.Equal(t, "Chrome", browser.Browser)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	browser, err = SearchBrowserContext(ctx, FILE, TEST_USER_AGENT)
+	assert.Equal(t, context.Canceled, err)
+	assert.Nil(t, browser)
+
+	/* the search is canceled even if no batch has to be matched */
+	iniFile, err := LoadIniReader(strings.NewReader(TEST_SMALL_INI), 10)
+	require.NoError(t, err)
+	require.Empty(t, candidateBatches(iniFile, "Unknown/1.0"))
+	browser, err = SearchBrowserContext(ctx, iniFile, "Unknown/1.0")
+	assert.Equal(t, context.Canceled, err)
+	assert.Nil(t, browser)
+
+	results := SearchBrowsersContext(ctx, iniFile, []string{"Unknown/1.0"})
+	require.Len(t, results, 1)
+	assert.Equal(t, context.Canceled, results[0].Err)
+}
+
+func TestPrecomputedBrowsers(t *testing.T) {
+	iniFile, err := LoadIniReader(strings.NewReader(TEST_SMALL_INI), 10)
+	require.NoError(t, err)
+	precomputed, err := LoadIniReader(strings.NewReader(TEST_SMALL_INI), 10, WithPrecomputedBrowsers())
+	require.NoError(t, err)
+
+	expected, err := SearchBrowser(iniFile, "TestBrowser/1.5")
+	require.NoError(t, err)
+
+	browser, err := SearchBrowser(precomputed, "TestBrowser/1.5")
+	require.NoError(t, err)
+	assert.Equal(t, expected, browser)
+	browser.Version = "2.0"
+
+	again, err := SearchBrowser(precomputed, "TestBrowser/1.5")
+	require.NoError(t, err)
+	assert.Equal(t, expected, again)
+	assert.NotSame(t, browser, again)
+}
+
+func TestSearchAllBrowsers(t *testing.T) {
+	iniFile, err := LoadIniReader(strings.NewReader(TEST_SMALL_INI+`
+[TestBrowser/*]
+Parent="Test Browser"
+`), 10)
+	require.NoError(t, err)
+
+	matches := SearchAllBrowsers(iniFile, "TestBrowser/1.5")
+	require.Len(t, matches, 2)
+	assert.Equal(t, "TestBrowser/1.*", matches[0].Section)
+	assert.Equal(t, "1.0", matches[0].Browser.Version)
+	assert.Equal(t, "TestBrowser/*", matches[1].Section)
+	assert.Equal(t, "", matches[1].Browser.Version)
+	assert.Less(t, matches[0].Position, matches[1].Position)
+
+	browser, err := SearchBrowser(iniFile, "TestBrowser/1.5")
+	require.NoError(t, err)
+	assert.Equal(t, browser, matches[0].Browser)
+
+	assert.Empty(t, SearchAllBrowsers(iniFile, "Unknown/1.0"))
+}
+
+func BenchmarkSearchBrowserPrecomputed(b *testing.B) {
+	data, err := ioutil.ReadFile("test-data/user_agents_sample.txt")
+	require.NoError(b, err)
+
+	uas := strings.Split(strings.TrimSpace(string(data)), "\n")
+
+	for _, bench := range []struct {
+		name    string
+		options []Option
+	}{{"merged", nil}, {"precomputed", []Option{WithPrecomputedBrowsers()}}} {
+		iniFile, err := LoadIniFile(TEST_INI_FILE, 10, bench.options...)
+		require.NoError(b, err)
+
+		b.Run(bench.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := SearchBrowser(iniFile, uas[i%len(uas)]); err != nil {
+					b.Error(err)
+				}
+			}
+		})
+		iniFile.Close()
+	}
+}
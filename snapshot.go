@@ -0,0 +1,433 @@
+package gobrowscap
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/gob"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"time"
+)
+
+const snapshotFormatVersion = 3
+
+/* the snapshot of the full browscap file is well below that, a larger length means a corrupt header */
+const maxSnapshotPayload = 1 << 30
+
+var snapshotMagic = [8]byte{'G', 'B', 'C', 'S', 'N', 'A', 'P', 0}
+
+type snapshotHeader struct {
+	Magic          [8]byte
+	FormatVersion  uint32
+	SourceChecksum [sha256.Size]byte
+	PayloadCRC     uint32
+	PayloadLength  uint64
+}
+
+type snapshot struct {
+	Version   string
+	Released  time.Time
+	Type      string
+	BatchSize int
+	Patterns  []snapshotPattern
+	Sections  []snapshotSection
+	Batches   []snapshotBatch
+	Index     snapshotIndex
+}
+
+type snapshotIndex struct {
+	Tokens map[string][]int
+	Always []int
+	Words  [][]string
+}
+
+type snapshotBatch struct {
+	PatternStr string
+	Start      int
+	End        int
+}
+
+type snapshotPattern struct {
+	Priority    int
+	Position    int
+	Length      int
+	ShortLength int
+	PatternStr  string
+	Intval      int
+	Matches     map[string]int
+}
+
+/* the typed section fields are restored from the properties */
+type snapshotSection struct {
+	Name       string
+	Line       int
+	Parent     int
+	Properties [][2]string
+	Overlay    string
+}
+
+// SaveSnapshot writes the fully processed iniFile to path, so that it can be restored with
+// LoadSnapshot without parsing, deduplicating and sorting the browscap data again.
+func SaveSnapshot(iniFile *IniFile, path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	writer := bufio.NewWriter(file)
+	if err := WriteSnapshot(iniFile, writer); err != nil {
+		file.Close()
+		return err
+	}
+
+	if err := writer.Flush(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// WriteSnapshot writes the fully processed iniFile to writer in the snapshot format.
+func WriteSnapshot(iniFile *IniFile, writer io.Writer) error {
+	data := snapshot{
+		Version:   iniFile.version,
+		Released:  iniFile.released,
+		Type:      iniFile.fileType,
+		BatchSize: iniFile.batchSize,
+		Patterns:  make([]snapshotPattern, len(iniFile.patterns)),
+		Sections:  make([]snapshotSection, len(iniFile.sections)),
+		Batches:   make([]snapshotBatch, len(iniFile.batches)),
+	}
+
+	for i, pattern := range iniFile.patterns {
+		data.Patterns[i] = snapshotPattern{
+			Priority:    pattern.priority,
+			Position:    pattern.position,
+			Length:      pattern.length,
+			ShortLength: pattern.shortLength,
+			PatternStr:  pattern.patternStr,
+			Intval:      pattern.intval,
+			Matches:     pattern.matches,
+		}
+	}
+
+	for i := 0; i < len(iniFile.sections); i++ {
+		section := iniFile.sections[i]
+		properties := make([][2]string, len(section.properties))
+		for j, property := range section.properties {
+			properties[j] = [2]string{property.key, property.value}
+		}
+		data.Sections[i] = snapshotSection{
+			Name:       section.name,
+			Line:       section.line,
+			Parent:     section.parent,
+			Properties: properties,
+			Overlay:    section.overlay,
+		}
+	}
+
+	for i, batch := range iniFile.batches {
+		data.Batches[i] = snapshotBatch{PatternStr: batch.patternStr, Start: batch.start, End: batch.end}
+	}
+
+	data.Index = snapshotIndex{
+		Tokens: iniFile.index.tokens,
+		Always: iniFile.index.always,
+		Words:  iniFile.index.words,
+	}
+
+	var payload bytes.Buffer
+	if err := gob.NewEncoder(&payload).Encode(&data); err != nil {
+		return fmt.Errorf("failed to encode snapshot: %w", err)
+	}
+
+	header := snapshotHeader{
+		Magic:          snapshotMagic,
+		FormatVersion:  snapshotFormatVersion,
+		SourceChecksum: iniFile.checksum,
+		PayloadCRC:     crc32.ChecksumIEEE(payload.Bytes()),
+		PayloadLength:  uint64(payload.Len()),
+	}
+
+	if err := binary.Write(writer, binary.LittleEndian, &header); err != nil {
+		return err
+	}
+	_, err := writer.Write(payload.Bytes())
+	return err
+}
+
+// LoadSnapshot restores an IniFile saved with SaveSnapshot. The regular expressions
+// are compiled with the matcher selected by the options on their first use, so the first
+// searches are slower. The snapshot is decoded into the heap, it can't be memory mapped.
+func LoadSnapshot(path string, opts ...Option) (*IniFile, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	iniFile, err := ReadSnapshot(bufio.NewReader(file), opts...)
+	if err != nil {
+		return nil, err
+	}
+	iniFile.source = path
+	return iniFile, nil
+}
+
+// ReadSnapshot restores an IniFile written with WriteSnapshot from reader.
+func ReadSnapshot(reader io.Reader, opts ...Option) (*IniFile, error) {
+	return readSnapshot(reader, newOptions(opts))
+}
+
+func readSnapshot(reader io.Reader, options *options) (*IniFile, error) {
+	start := time.Now()
+	header, err := readSnapshotHeader(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	if header.PayloadLength > maxSnapshotPayload {
+		return nil, fmt.Errorf("invalid snapshot payload length %d", header.PayloadLength)
+	}
+
+	/* the buffer grows with the data actually read, not with the length in the header */
+	var buffer bytes.Buffer
+	if _, err := io.CopyN(&buffer, reader, int64(header.PayloadLength)); err != nil {
+		return nil, fmt.Errorf("failed to read snapshot payload: %w", err)
+	}
+	payload := buffer.Bytes()
+
+	if crc32.ChecksumIEEE(payload) != header.PayloadCRC {
+		return nil, fmt.Errorf("snapshot payload checksum mismatch")
+	}
+
+	var data snapshot
+	if err := gob.NewDecoder(bytes.NewReader(payload)).Decode(&data); err != nil {
+		return nil, fmt.Errorf("failed to decode snapshot: %w", err)
+	}
+	if err := data.validate(); err != nil {
+		return nil, fmt.Errorf("invalid snapshot: %w", err)
+	}
+
+	sections := make(map[int]*IniSection, len(data.Sections))
+	keys := make(map[string]string)
+	for i, sectionData := range data.Sections {
+		section := new(IniSection)
+		section.name = sectionData.Name
+		section.line = sectionData.Line
+		for _, property := range sectionData.Properties {
+			key, ok := keys[property[0]]
+			if !ok {
+				key = property[0]
+				keys[key] = key
+			}
+			if _, err := parseSectionValues(section, key, property[1], 0); err != nil {
+				return nil, err
+			}
+		}
+		section.parent = sectionData.Parent
+		section.overlay = sectionData.Overlay
+		sections[i] = section
+	}
+
+	patterns := make([]*Pattern, len(data.Patterns))
+	for i, patternData := range data.Patterns {
+		pattern := new(Pattern)
+		pattern.priority = patternData.Priority
+		pattern.position = patternData.Position
+		pattern.length = patternData.Length
+		pattern.shortLength = patternData.ShortLength
+		pattern.patternStr = patternData.PatternStr
+		pattern.regex = newLazyRegexp(options.matcher, "^"+patternData.PatternStr+"$")
+		pattern.intval = patternData.Intval
+		pattern.matches = patternData.Matches
+		patterns[i] = pattern
+	}
+
+	/* the first search matching a batch compiles it, the rest of the data is ready to use */
+	batches := make([]*Batch, len(data.Batches))
+	for i, batchData := range data.Batches {
+		batch := new(Batch)
+		batch.regex = newLazyRegexp(options.matcher, batchData.PatternStr)
+		batch.patternStr = batchData.PatternStr
+		batch.index = i
+		batch.start = batchData.Start
+		batch.end = batchData.End
+		batches[i] = batch
+	}
+
+	iniFile := newIniFile(options)
+	iniFile.patterns = patterns
+	iniFile.sections = sections
+	iniFile.batches = batches
+	iniFile.index = &tokenIndex{tokens: data.Index.Tokens, always: data.Index.Always, words: data.Index.Words}
+
+	if options.precomputeBrowsers {
+		precomputeBrowsers(iniFile)
+	}
+	iniFile.batchSize = data.BatchSize
+	iniFile.version = data.Version
+	iniFile.released = data.Released
+	iniFile.fileType = data.Type
+	iniFile.checksum = header.SourceChecksum
+	iniFile.loadDuration = time.Since(start)
+
+	return iniFile, nil
+}
+
+/* the indexes are used without bounds checks by the searches */
+func (data *snapshot) validate() error {
+	inRange := func(index int, length int) bool {
+		return index >= 0 && index < length
+	}
+
+	for _, section := range data.Sections {
+		if !inRange(section.Parent, len(data.Sections)) {
+			return fmt.Errorf("section '%s' has an invalid parent %d", section.Name, section.Parent)
+		}
+	}
+	if err := data.validateParents(); err != nil {
+		return err
+	}
+	for _, pattern := range data.Patterns {
+		if pattern.Matches == nil && !inRange(pattern.Intval, len(data.Sections)) {
+			return fmt.Errorf("pattern '%s' refers to an invalid section %d", pattern.PatternStr, pattern.Intval)
+		}
+		for _, key := range pattern.Matches {
+			if !inRange(key, len(data.Sections)) {
+				return fmt.Errorf("pattern '%s' refers to an invalid section %d", pattern.PatternStr, key)
+			}
+		}
+	}
+
+	end := 0
+	for i, batch := range data.Batches {
+		if batch.Start != end || batch.End < batch.Start || batch.End > len(data.Patterns) {
+			return fmt.Errorf("batch %d has an invalid range %d-%d", i, batch.Start, batch.End)
+		}
+		end = batch.End
+	}
+	if end != len(data.Patterns) {
+		return fmt.Errorf("the batches cover %d patterns of %d", end, len(data.Patterns))
+	}
+
+	if len(data.Index.Words) != len(data.Patterns) {
+		return fmt.Errorf("the index has %d patterns, expected %d", len(data.Index.Words), len(data.Patterns))
+	}
+	lists := [][]int{data.Index.Always}
+	for _, indexes := range data.Index.Tokens {
+		lists = append(lists, indexes)
+	}
+	for _, indexes := range lists {
+		for _, index := range indexes {
+			if !inRange(index, len(data.Patterns)) {
+				return fmt.Errorf("the index refers to an invalid pattern %d", index)
+			}
+		}
+	}
+	return nil
+}
+
+/* the parents are followed until a section without one, a cycle would never end */
+func (data *snapshot) validateParents() error {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+
+	hasParent := func(section snapshotSection) bool {
+		parentName := ""
+		for _, property := range section.Properties {
+			if property[0] == "Parent" {
+				parentName = property[1]
+			}
+		}
+		return parentName != ""
+	}
+
+	states := make([]int, len(data.Sections))
+	var path []int
+	for i := range data.Sections {
+		path = path[:0]
+		index := i
+		for states[index] == unvisited {
+			states[index] = visiting
+			path = append(path, index)
+			if !hasParent(data.Sections[index]) {
+				break
+			}
+			index = data.Sections[index].Parent
+		}
+		if states[index] == visiting && hasParent(data.Sections[index]) {
+			return fmt.Errorf("section '%s' inherits from itself", data.Sections[index].Name)
+		}
+		for _, index := range path {
+			states[index] = visited
+		}
+	}
+	return nil
+}
+
+// SnapshotIsStale reports whether the snapshot at snapshotPath was created from data
+// other than the current contents of the browscap file at iniPath and the overlays,
+// which have to be the ones passed to WithOverlays when the data was loaded.
+func SnapshotIsStale(snapshotPath string, iniPath string, overlays ...string) (bool, error) {
+	snapshotFile, err := os.Open(snapshotPath)
+	if err != nil {
+		return false, err
+	}
+	defer snapshotFile.Close()
+
+	header, err := readSnapshotHeader(snapshotFile)
+	if err != nil {
+		return false, err
+	}
+
+	checksums := make([][sha256.Size]byte, 0, len(overlays)+1)
+	for _, path := range append([]string{iniPath}, overlays...) {
+		checksum, err := fileChecksum(path)
+		if err != nil {
+			return false, err
+		}
+		checksums = append(checksums, checksum)
+	}
+	return combineChecksums(checksums) != header.SourceChecksum, nil
+}
+
+func fileChecksum(path string) ([sha256.Size]byte, error) {
+	var checksum [sha256.Size]byte
+
+	file, err := os.Open(path)
+	if err != nil {
+		return checksum, err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return checksum, err
+	}
+	copy(checksum[:], hash.Sum(nil))
+	return checksum, nil
+}
+
+func readSnapshotHeader(reader io.Reader) (*snapshotHeader, error) {
+	header := new(snapshotHeader)
+	if err := binary.Read(reader, binary.LittleEndian, header); err != nil {
+		return nil, fmt.Errorf("failed to read snapshot header: %w", err)
+	}
+
+	if header.Magic != snapshotMagic {
+		return nil, fmt.Errorf("not a gobrowscap snapshot")
+	}
+
+	if header.FormatVersion != snapshotFormatVersion {
+		return nil, fmt.Errorf("unsupported snapshot format version %d, expected %d", header.FormatVersion, snapshotFormatVersion)
+	}
+	return header, nil
+}
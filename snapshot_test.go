@@ -0,0 +1,179 @@
+package gobrowscap
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"hash/crc32"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSnapshot(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gobrowscap")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	snapshotPath := filepath.Join(dir, "browscap.snapshot")
+	require.NoError(t, SaveSnapshot(FILE, snapshotPath))
+
+	stale, err := SnapshotIsStale(snapshotPath, TEST_INI_FILE)
+	require.NoError(t, err)
+	assert.False(t, stale)
+
+	stale, err = SnapshotIsStale(snapshotPath, "test-data/user_agents_sample.txt")
+	require.NoError(t, err)
+	assert.True(t, stale)
+
+	iniFile, err := LoadSnapshot(snapshotPath)
+	require.NoError(t, err)
+	assert.Equal(t, GetFileVersion(FILE), GetFileVersion(iniFile))
+	assert.True(t, GetFileMetadata(FILE).Released.Equal(GetFileMetadata(iniFile).Released))
+	assert.Equal(t, snapshotPath, GetFileMetadata(iniFile).Source)
+
+	for _, ua := range []string{TEST_USER_AGENT, TEST_IPHONE_AGENT, TEST_YANDEX_AGENT, TEST_ANDROID_AGENT, TEST_MOBILE_FIREFOX} {
+		expected, err := SearchBrowser(FILE, ua)
+		require.NoError(t, err)
+		browser, err := SearchBrowser(iniFile, ua)
+		require.NoError(t, err)
+		assert.Equal(t, expected, browser)
+	}
+	assert.Equal(t, len(FILE.index.words), len(iniFile.index.words))
+	assert.Equal(t, FILE.index.candidates(TEST_USER_AGENT), iniFile.index.candidates(TEST_USER_AGENT))
+
+	/* the payload length is checked before the payload is read */
+	var snapshot bytes.Buffer
+	require.NoError(t, WriteSnapshot(FILE, &snapshot))
+	lengthOffset := binary.Size(snapshotHeader{}) - 8
+	for _, length := range []uint64{1 << 62, uint64(snapshot.Len())} {
+		data := append([]byte(nil), snapshot.Bytes()...)
+		binary.LittleEndian.PutUint64(data[lengthOffset:], length)
+		_, err = ReadSnapshot(bytes.NewReader(data))
+		require.Error(t, err)
+	}
+}
+
+func TestSnapshotParentCycle(t *testing.T) {
+	iniFile, err := LoadIniReader(strings.NewReader(TEST_SMALL_INI), 10)
+	require.NoError(t, err)
+
+	/* DefaultProperties inherits from TestBrowser/1.*, which inherits from it through Test Browser */
+	indexes := make(map[string]int)
+	for index, section := range iniFile.sections {
+		indexes[section.name] = index
+	}
+	root := iniFile.sections[indexes["DefaultProperties"]]
+	parseSectionValues(root, "Parent", "TestBrowser/1.*", 0)
+	root.parent = indexes["TestBrowser/1.*"]
+
+	var snapshot bytes.Buffer
+	require.NoError(t, WriteSnapshot(iniFile, &snapshot))
+	_, err = ReadSnapshot(&snapshot)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "inherits from itself")
+}
+
+/* re-encodes the payload of a valid snapshot with a valid checksum after modify changes it */
+func rewriteSnapshot(t *testing.T, data []byte, modify func(*snapshot)) []byte {
+	headerSize := binary.Size(snapshotHeader{})
+	var header snapshotHeader
+	require.NoError(t, binary.Read(bytes.NewReader(data), binary.LittleEndian, &header))
+	var decoded snapshot
+	require.NoError(t, gob.NewDecoder(bytes.NewReader(data[headerSize:])).Decode(&decoded))
+
+	modify(&decoded)
+	var payload bytes.Buffer
+	require.NoError(t, gob.NewEncoder(&payload).Encode(&decoded))
+	header.PayloadCRC = crc32.ChecksumIEEE(payload.Bytes())
+	header.PayloadLength = uint64(payload.Len())
+
+	var rewritten bytes.Buffer
+	require.NoError(t, binary.Write(&rewritten, binary.LittleEndian, &header))
+	rewritten.Write(payload.Bytes())
+	return rewritten.Bytes()
+}
+
+func TestSnapshotCorruption(t *testing.T) {
+	iniFile, err := LoadIniFile(TEST_INI_FILE, 2)
+	require.NoError(t, err)
+	require.Greater(t, len(iniFile.batches), 2)
+	var buffer bytes.Buffer
+	require.NoError(t, WriteSnapshot(iniFile, &buffer))
+	valid := buffer.Bytes()
+	headerSize := binary.Size(snapshotHeader{})
+
+	modified := func(modify func(data []byte)) []byte {
+		data := append([]byte(nil), valid...)
+		modify(data)
+		return data
+	}
+	garbage := append([]byte(nil), valid[:headerSize]...)
+	garbage = append(garbage, bytes.Repeat([]byte{0xff}, 64)...)
+	binary.LittleEndian.PutUint32(garbage[headerSize-12:], crc32.ChecksumIEEE(garbage[headerSize:]))
+	binary.LittleEndian.PutUint64(garbage[headerSize-8:], 64)
+
+	for _, test := range []struct {
+		name string
+		data []byte
+		err  string
+	}{
+		{"empty", nil, "failed to read snapshot header"},
+		{"truncated header", valid[:headerSize-1], "failed to read snapshot header"},
+		{"magic", modified(func(data []byte) { data[0] = 'X' }), "not a gobrowscap snapshot"},
+		{"format version", modified(func(data []byte) { data[8]++ }), "unsupported snapshot format version"},
+		{"truncated payload", valid[:len(valid)-1], "failed to read snapshot payload"},
+		{"flipped payload byte", modified(func(data []byte) { data[len(data)-1] ^= 0x01 }), "checksum mismatch"},
+		{"undecodable payload", garbage, "failed to decode snapshot"},
+		{"section parent", rewriteSnapshot(t, valid, func(data *snapshot) {
+			data.Sections[1].Parent = len(data.Sections)
+		}), "invalid parent"},
+		{"pattern section", rewriteSnapshot(t, valid, func(data *snapshot) {
+			data.Patterns[0].Matches = nil
+			data.Patterns[0].Intval = -1
+		}), "refers to an invalid section"},
+		{"batch gap", rewriteSnapshot(t, valid, func(data *snapshot) {
+			data.Batches[1].Start++
+		}), "invalid range"},
+		{"missing batch", rewriteSnapshot(t, valid, func(data *snapshot) {
+			data.Batches = data.Batches[:len(data.Batches)-1]
+		}), "the batches cover"},
+		{"index patterns", rewriteSnapshot(t, valid, func(data *snapshot) {
+			data.Index.Words = data.Index.Words[1:]
+		}), "the index has"},
+		{"index pattern", rewriteSnapshot(t, valid, func(data *snapshot) {
+			data.Index.Always = append(data.Index.Always, len(data.Patterns))
+		}), "the index refers to an invalid pattern"},
+		{"self parent", rewriteSnapshot(t, valid, func(data *snapshot) {
+			data.Sections[1].Parent = 1
+			data.Sections[1].Properties = append(data.Sections[1].Properties, [2]string{"Parent", data.Sections[1].Name})
+		}), "inherits from itself"},
+	} {
+		_, err := ReadSnapshot(bytes.NewReader(test.data))
+		require.Error(t, err, test.name)
+		assert.Contains(t, err.Error(), test.err, test.name)
+	}
+
+	_, err = ReadSnapshot(bytes.NewReader(rewriteSnapshot(t, valid, func(*snapshot) {})))
+	assert.NoError(t, err)
+}
+
+func BenchmarkLoadSnapshot(b *testing.B) {
+	dir, err := ioutil.TempDir("", "gobrowscap")
+	require.NoError(b, err)
+	defer os.RemoveAll(dir)
+
+	snapshotPath := filepath.Join(dir, "browscap.snapshot")
+	require.NoError(b, SaveSnapshot(FILE, snapshotPath))
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := LoadSnapshot(snapshotPath)
+		require.NoError(b, err)
+	}
+}
@@ -0,0 +1,19 @@
+package gobrowscap
+
+// Stats holds the sizes of a loaded IniFile.
+type Stats struct {
+	Sections  int
+	Patterns  int
+	Batches   int
+	BatchSize int
+}
+
+// GetFileStats returns the number of sections, patterns and batch regexes in iniFile.
+func GetFileStats(iniFile *IniFile) Stats {
+	return Stats{
+		Sections:  len(iniFile.sections),
+		Patterns:  len(iniFile.patterns),
+		Batches:   len(iniFile.batches),
+		BatchSize: iniFile.batchSize,
+	}
+}
@@ -1,5 +1,34 @@
 package gobrowscap
 
+import (
+	"time"
+)
+
+// Metadata describes a loaded IniFile.
+type Metadata struct {
+	Version string
+	// Released is the release date of the data, zero if it's missing or malformed.
+	Released time.Time
+	// Type is the browscap edition: LITE, STANDARD or FULL.
+	Type string
+	// Source is the path the data was loaded from, empty when it was read from an io.Reader.
+	Source       string
+	LoadDuration time.Duration
+	Stats
+}
+
 func GetFileVersion(iniFile *IniFile) string {
 	return iniFile.version
 }
+
+// GetFileMetadata returns the metadata of iniFile, e.g. to report it on health endpoints.
+func GetFileMetadata(iniFile *IniFile) Metadata {
+	return Metadata{
+		Version:      iniFile.version,
+		Released:     iniFile.released,
+		Type:         iniFile.fileType,
+		Source:       iniFile.source,
+		LoadDuration: iniFile.loadDuration,
+		Stats:        GetFileStats(iniFile),
+	}
+}
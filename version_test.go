@@ -0,0 +1,31 @@
+package gobrowscap
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetadata(t *testing.T) {
+	metadata := GetFileMetadata(FILE)
+	assert.Equal(t, GetFileVersion(FILE), metadata.Version)
+	assert.Equal(t, "FULL", metadata.Type)
+	assert.Equal(t, TEST_INI_FILE, metadata.Source)
+	assert.False(t, metadata.Released.IsZero())
+	assert.Greater(t, int64(metadata.LoadDuration), int64(0))
+	assert.Equal(t, GetFileStats(FILE), metadata.Stats)
+
+	csvFile, err := LoadCSVReader(strings.NewReader(TEST_SMALL_CSV), 10)
+	require.NoError(t, err)
+	metadata = GetFileMetadata(csvFile)
+	assert.Equal(t, time.Date(2021, 10, 7, 10, 48, 5, 0, time.UTC), metadata.Released.UTC())
+	assert.Empty(t, metadata.Source)
+
+	/* the version section of TEST_SMALL_INI has no release date */
+	iniFile, err := LoadIniReader(strings.NewReader(TEST_SMALL_INI), 10)
+	require.NoError(t, err)
+	assert.True(t, GetFileMetadata(iniFile).Released.IsZero())
+}
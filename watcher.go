@@ -0,0 +1,240 @@
+package gobrowscap
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// WatchEventType tells what happened after the watched file changed.
+type WatchEventType int
+
+const (
+	// WatchReloaded means the changed file was loaded and is used for the searches.
+	WatchReloaded WatchEventType = iota + 1
+	// WatchFailed means the changed file could not be loaded, the previous one is still used.
+	WatchFailed
+)
+
+func (t WatchEventType) String() string {
+	switch t {
+	case WatchReloaded:
+		return "reloaded"
+	case WatchFailed:
+		return "failed"
+	}
+	return "unknown"
+}
+
+// WatchEvent is sent by Watcher after every reload attempt.
+type WatchEvent struct {
+	Type    WatchEventType
+	Path    string /* of the reloaded browscap file, also when one of its overlays changed */
+	Version string /* of the IniFile used after the event */
+	Err     error
+	Time    time.Time
+}
+
+const (
+	defaultWatchDebounce     = time.Second
+	defaultWatchPollInterval = 10 * time.Second
+	watchEventsBuffer        = 16
+)
+
+// Watcher reloads a Detector whenever the browscap file it was loaded from
+// or one of the overlays it was loaded with changes on disk.
+type Watcher struct {
+	detector     *Detector
+	path         string
+	paths        []string /* the file and its overlays */
+	debounce     time.Duration
+	pollInterval time.Duration
+	forcePolling bool
+
+	fileInfos []os.FileInfo
+	notifier  *fileNotifier
+	events    chan WatchEvent
+	done      chan struct{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+}
+
+// WatchOption configures a Watcher.
+type WatchOption func(*Watcher)
+
+// WatchDebounce sets how long the file has to stay unchanged before it's reloaded, 1s by default.
+func WatchDebounce(debounce time.Duration) WatchOption {
+	return func(w *Watcher) {
+		w.debounce = debounce
+	}
+}
+
+// WatchPolling makes the watcher check the file every interval instead of relying on
+// the file system notifications, which are not delivered on some network file systems.
+// Polling is also used when the notifications are not available on the platform.
+// WatchFile fails if interval is not positive.
+func WatchPolling(interval time.Duration) WatchOption {
+	return func(w *Watcher) {
+		w.forcePolling = true
+		w.pollInterval = interval
+	}
+}
+
+/* returns nil if the file doesn't exist */
+func statFile(path string) os.FileInfo {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil
+	}
+	return info
+}
+
+func fileChanged(a os.FileInfo, b os.FileInfo) bool {
+	if a == nil || b == nil {
+		return a != b
+	}
+	return !os.SameFile(a, b) || !a.ModTime().Equal(b.ModTime()) || a.Size() != b.Size()
+}
+
+// WatchFile starts watching the browscap file at path and reloads detector when it changes.
+// The file is expected to be the one detector is currently loaded from,
+// the overlays the detector was loaded with are watched as well.
+func WatchFile(detector *Detector, path string, opts ...WatchOption) (*Watcher, error) {
+	w := new(Watcher)
+	w.detector = detector
+	w.path = path
+	w.paths = append([]string{path}, detector.IniFile().options.overlays...)
+	w.debounce = defaultWatchDebounce
+	w.pollInterval = defaultWatchPollInterval
+	for _, opt := range opts {
+		opt(w)
+	}
+	if w.pollInterval <= 0 {
+		return nil, fmt.Errorf("invalid poll interval %s", w.pollInterval)
+	}
+
+	w.fileInfos = make([]os.FileInfo, len(w.paths))
+	for i, path := range w.paths {
+		fileInfo, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		w.fileInfos[i] = fileInfo
+	}
+
+	w.events = make(chan WatchEvent, watchEventsBuffer)
+	w.done = make(chan struct{})
+
+	changes := make(chan struct{}, 1)
+	if !w.forcePolling {
+		notifier, err := newFileNotifier(w.paths, changes)
+		if err == nil {
+			w.notifier = notifier
+		}
+	}
+
+	if w.notifier == nil {
+		w.wg.Add(1)
+		go w.poll(changes)
+	}
+
+	w.wg.Add(1)
+	go w.run(changes)
+	return w, nil
+}
+
+// Events returns the channel receiving the result of every reload attempt.
+// Events are dropped when the channel is not read. The channel is closed by Close.
+func (w *Watcher) Events() <-chan WatchEvent {
+	return w.events
+}
+
+// Close stops watching the file.
+func (w *Watcher) Close() error {
+	var err error
+	w.closeOnce.Do(func() {
+		close(w.done)
+		if w.notifier != nil {
+			err = w.notifier.Close()
+		}
+		w.wg.Wait()
+		close(w.events)
+	})
+	return err
+}
+
+func (w *Watcher) poll(changes chan<- struct{}) {
+	defer w.wg.Done()
+
+	ticker := time.NewTicker(w.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-ticker.C:
+			notifyChange(changes)
+		}
+	}
+}
+
+func (w *Watcher) run(changes <-chan struct{}) {
+	defer w.wg.Done()
+
+	debounce := time.NewTimer(w.debounce)
+	debounce.Stop()
+	defer debounce.Stop()
+
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-changes:
+			if !debounce.Stop() {
+				select {
+				case <-debounce.C:
+				default:
+				}
+			}
+			debounce.Reset(w.debounce)
+		case <-debounce.C:
+			w.reload()
+		}
+	}
+}
+
+func (w *Watcher) reload() {
+	changed := false
+	for i, path := range w.paths {
+		fileInfo := statFile(path)
+		if fileChanged(w.fileInfos[i], fileInfo) {
+			w.fileInfos[i] = fileInfo
+			changed = true
+		}
+	}
+	if !changed {
+		return
+	}
+
+	event := WatchEvent{Type: WatchReloaded, Path: w.path, Time: time.Now()}
+	if err := w.detector.Reload(w.path); err != nil {
+		event.Type = WatchFailed
+		event.Err = err
+	}
+	event.Version = w.detector.Version()
+
+	select {
+	case w.events <- event:
+	default:
+	}
+}
+
+func notifyChange(changes chan<- struct{}) {
+	select {
+	case changes <- struct{}{}:
+	default:
+		/* a change is already pending */
+	}
+}
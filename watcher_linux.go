@@ -0,0 +1,58 @@
+package gobrowscap
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// the directory is watched instead of the file itself, so that replacing the file
+// with a rename or a symlink swap is noticed as well
+const inotifyMask = syscall.IN_CLOSE_WRITE | syscall.IN_MOVED_TO | syscall.IN_CREATE | syscall.IN_DELETE | syscall.IN_ATTRIB
+
+type fileNotifier struct {
+	file *os.File
+	done chan struct{}
+}
+
+func newFileNotifier(paths []string, changes chan<- struct{}) (*fileNotifier, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, err
+	}
+
+	/* adding the same directory again only updates its watch */
+	for _, path := range paths {
+		if _, err := syscall.InotifyAddWatch(fd, filepath.Dir(path), inotifyMask); err != nil {
+			syscall.Close(fd)
+			return nil, err
+		}
+	}
+
+	/* a non-blocking descriptor goes to the runtime poller, so Close interrupts the pending Read */
+	n := &fileNotifier{
+		file: os.NewFile(uintptr(fd), "inotify"),
+		done: make(chan struct{}),
+	}
+	go n.read(changes)
+	return n, nil
+}
+
+func (n *fileNotifier) read(changes chan<- struct{}) {
+	defer close(n.done)
+
+	buf := make([]byte, 64*(syscall.SizeofInotifyEvent+syscall.NAME_MAX+1))
+	for {
+		if _, err := n.file.Read(buf); err != nil {
+			return
+		}
+		/* the debounced reload checks whether the file itself changed, the events don't matter */
+		notifyChange(changes)
+	}
+}
+
+func (n *fileNotifier) Close() error {
+	err := n.file.Close()
+	<-n.done
+	return err
+}
@@ -0,0 +1,18 @@
+//go:build !linux
+
+package gobrowscap
+
+import (
+	"errors"
+)
+
+type fileNotifier struct{}
+
+/* file system notifications are implemented for Linux only, the watcher falls back to polling */
+func newFileNotifier(paths []string, changes chan<- struct{}) (*fileNotifier, error) {
+	return nil, errors.New("file notifications are not supported on this platform")
+}
+
+func (n *fileNotifier) Close() error {
+	return nil
+}
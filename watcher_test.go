@@ -0,0 +1,72 @@
+package gobrowscap
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatchFile(t *testing.T) {
+	for name, opts := range map[string][]WatchOption{
+		"notify":  {WatchDebounce(10 * time.Millisecond)},
+		"polling": {WatchDebounce(10 * time.Millisecond), WatchPolling(10 * time.Millisecond)},
+	} {
+		dir, err := ioutil.TempDir("", "gobrowscap")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+
+		path := filepath.Join(dir, "browscap.ini")
+		require.NoError(t, ioutil.WriteFile(path, []byte(TEST_SMALL_INI), 0644))
+
+		iniFile, err := LoadIniFile(path, 10)
+		require.NoError(t, err)
+		detector := NewDetector(iniFile)
+
+		watcher, err := WatchFile(detector, path, opts...)
+		require.NoError(t, err)
+
+		tmpPath := filepath.Join(dir, "browscap.ini.tmp")
+		require.NoError(t, ioutil.WriteFile(tmpPath, []byte(strings.Replace(TEST_SMALL_INI, "Version=1", "Version=2", 1)), 0644))
+		require.NoError(t, os.Rename(tmpPath, path))
+
+		select {
+		case event := <-watcher.Events():
+			assert.Equal(t, WatchReloaded, event.Type, name)
+			assert.NoError(t, event.Err, name)
+			assert.Equal(t, "2", event.Version, name)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%s: no reload event", name)
+		}
+		assert.Equal(t, "2", detector.Version(), name)
+
+		require.NoError(t, ioutil.WriteFile(path, []byte("[Broken]\nisTablet=maybe\n"), 0644))
+		select {
+		case event := <-watcher.Events():
+			assert.Equal(t, WatchFailed, event.Type, name)
+			assert.Error(t, event.Err, name)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%s: no failure event", name)
+		}
+		assert.Equal(t, "2", detector.Version(), name)
+
+		require.NoError(t, watcher.Close())
+	}
+}
+
+func TestWatchPollingInterval(t *testing.T) {
+	iniFile, err := LoadIniReader(strings.NewReader(TEST_SMALL_INI), 10)
+	require.NoError(t, err)
+	detector := NewDetector(iniFile)
+
+	for _, interval := range []time.Duration{0, -time.Second} {
+		watcher, err := WatchFile(detector, TEST_INI_FILE, WatchPolling(interval))
+		assert.Error(t, err, interval)
+		assert.Nil(t, watcher, interval)
+	}
+}
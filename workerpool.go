@@ -0,0 +1,131 @@
+package gobrowscap
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+/* long-lived goroutines matching the batch regexes of all the concurrent searches */
+type workerPool struct {
+	workers   int
+	users     int32 /* the IniFiles sharing the pool, the last one closed stops it */
+	jobs      chan batchJob
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+type batchJob struct {
+	batch     *Batch
+	position  int
+	userAgent string
+	results   chan<- int
+}
+
+func newWorkerPool(workers int) *workerPool {
+	pool := new(workerPool)
+	pool.workers = workers
+	pool.users = 1
+	pool.jobs = make(chan batchJob)
+	pool.done = make(chan struct{})
+
+	for i := 0; i < workers; i++ {
+		go pool.work()
+	}
+	return pool
+}
+
+func (pool *workerPool) work() {
+	for {
+		select {
+		case job := <-pool.jobs:
+			job.run()
+		case <-pool.done:
+			return
+		}
+	}
+}
+
+func (pool *workerPool) submit(job batchJob) {
+	select {
+	case pool.jobs <- job:
+	case <-pool.done:
+		/* the pool is closed, but the search has to be completed anyway */
+		job.run()
+	}
+}
+
+/* the copies of an IniFile made by AddPattern use the same workers */
+func (pool *workerPool) share() *workerPool {
+	atomic.AddInt32(&pool.users, 1)
+	return pool
+}
+
+func (pool *workerPool) release() {
+	if atomic.AddInt32(&pool.users, -1) == 0 {
+		pool.close()
+	}
+}
+
+func (pool *workerPool) close() {
+	pool.closeOnce.Do(func() {
+		close(pool.done)
+	})
+}
+
+/* sends the job position to the results channel if the batch matches or -1 otherwise */
+func (job batchJob) run() {
+	matched := false
+	defer func() {
+		if r := recover(); r != nil {
+			matched = false
+		}
+		if matched {
+			job.results <- job.position
+		} else {
+			job.results <- -1
+		}
+	}()
+	matched = job.batch.regex.MatchString(job.userAgent)
+}
+
+/* number of batches matched at once by a single search */
+func (iniFile *IniFile) roundSize() int {
+	if iniFile.pool != nil {
+		return iniFile.pool.workers
+	}
+	return runtime.NumCPU()
+}
+
+/* returns the positions of the matching batches in the slice in no particular order */
+func matchBatches(iniFile *IniFile, batches []*Batch, userAgent string, sequential bool) []int {
+	results := make(chan int, len(batches))
+	for position, batch := range batches {
+		job := batchJob{batch: batch, position: position, userAgent: userAgent, results: results}
+		if sequential {
+			job.run()
+		} else if iniFile.pool != nil {
+			iniFile.pool.submit(job)
+		} else {
+			go job.run()
+		}
+	}
+
+	foundPositions := make([]int, 0)
+	for range batches {
+		if result := <-results; result != -1 {
+			foundPositions = append(foundPositions, result)
+		}
+	}
+	return foundPositions
+}
+
+// Close stops the worker pool of the IniFile. It's not required, the pool is stopped once
+// the IniFile is garbage collected. The pool is shared with the copies of the IniFile made by
+// Detector.AddPattern and stopped once all of them are closed. Searches still work after Close,
+// but run in the calling goroutine.
+func (iniFile *IniFile) Close() {
+	if iniFile.pool != nil && atomic.CompareAndSwapUint32(&iniFile.closed, 0, 1) {
+		iniFile.pool.release()
+	}
+}
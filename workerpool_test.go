@@ -0,0 +1,40 @@
+package gobrowscap
+
+import (
+	"io/ioutil"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func BenchmarkSearchBrowserParallel(b *testing.B) {
+	data, err := ioutil.ReadFile("test-data/user_agents_sample.txt")
+	require.NoError(b, err)
+
+	uas := strings.Split(strings.TrimSpace(string(data)), "\n")
+
+	/* the worker pool is compared to a goroutine per batch on every search */
+	for _, bench := range []struct {
+		name    string
+		workers int
+	}{{"pool", runtime.NumCPU()}, {"goroutines", 0}} {
+		iniFile, err := LoadIniFile(TEST_INI_FILE, 10, WithWorkers(bench.workers))
+		require.NoError(b, err)
+
+		b.Run(bench.name, func(b *testing.B) {
+			var counter uint64
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					idx := atomic.AddUint64(&counter, 1) % uint64(len(uas))
+					if _, err := SearchBrowser(iniFile, uas[idx]); err != nil {
+						b.Error(err)
+					}
+				}
+			})
+		})
+		iniFile.Close()
+	}
+}